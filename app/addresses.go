@@ -0,0 +1,152 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// newHTTPPingAddresses pings each address the host of the target resolves to concurrently, the connections are forced
+// to the addresses while the requests are unchanged (Host header and SNI)
+func newHTTPPingAddresses(config *Config, consoleLogger ConsoleLogger, exporter *prometheusExporter) (HTTPPing, error) {
+	h := &httpPingMulti{
+		config:        config,
+		consoleLogger: consoleLogger,
+		title:         "addresses",
+		header:        "address",
+		phases:        []stats.TimerType{stats.TCP, stats.TLS, stats.Wait},
+		flagOutliers:  true,
+	}
+
+	u, err := url.Parse(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = portMap[u.Scheme]
+	}
+
+	backend, err := NewBackendResolver(config)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := newResolver(config, &RuntimeConfig{DNSBackend: backend}).resolve(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range resolved.addrs {
+		configCopy := *config
+		configCopy.ConnTarget = net.JoinHostPort(ip.String(), port)
+
+		if err := h.addSession(&configCopy, ip.String(), exporter); err != nil {
+			return nil, fmt.Errorf("%s: %s", ip, err)
+		}
+	}
+
+	return h, nil
+}
+
+// addressLabel returns the address pinged in a session, when each address of the target is pinged
+func addressLabel(config *Config) string {
+	if !config.AllAddresses {
+		return ""
+	}
+	host, _, _ := net.SplitHostPort(config.ConnTarget)
+	return host
+}
+
+const (
+	// outlierLatencyFactor is how many times the median latency of a session must exceed the one of the others to be
+	// flagged
+	outlierLatencyFactor = 1.5
+	// outlierLossMargin is how much the loss rate of a session must exceed the one of the others to be flagged
+	outlierLossMargin = 0.05
+)
+
+// findOutliers returns why each session stands out from the others, if it does: a median latency or a loss rate much
+// higher than the median of the other ones
+func findOutliers(rows []comparisonRow) []string {
+	reasons := make([]string, len(rows))
+	if len(rows) < 2 {
+		return reasons
+	}
+
+	p50 := func(row comparisonRow) float64 {
+		return stats.PingStatsFromHistogram(row.measures.latencies).P50.ToFloat(time.Millisecond)
+	}
+
+	for i, row := range rows {
+		var latencies, losses []float64
+		for j, other := range rows {
+			if j == i {
+				continue
+			}
+			losses = append(losses, other.measures.lossRate())
+			if other.measures.successes > 0 {
+				latencies = append(latencies, p50(other))
+			}
+		}
+
+		othersLoss := median(losses)
+		switch {
+		case row.measures.successes == 0 && len(latencies) > 0:
+			reasons[i] = "no answer received"
+		case row.measures.successes > 0 && len(latencies) > 0 && p50(row) > outlierLatencyFactor*median(latencies):
+			reasons[i] = fmt.Sprintf("median latency %.1f times the one of the others", p50(row)/median(latencies))
+		case row.measures.lossRate() > othersLoss+outlierLossMargin:
+			reasons[i] = fmt.Sprintf("loss %.1f%%, against %.1f%% for the others", row.measures.lossRate()*100, othersLoss*100)
+		}
+	}
+	return reasons
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// printOutliers prints the sessions standing out from the others
+func printOutliers(logger ConsoleLogger, rows []comparisonRow) {
+	found := false
+	for i, reason := range findOutliers(rows) {
+		if reason == "" {
+			continue
+		}
+		if !found {
+			_, _ = logger.Printf("\noutliers:\n")
+			found = true
+		}
+		_, _ = logger.Printf("  %s: %s\n", rows[i].label, reason)
+	}
+	if !found {
+		_, _ = logger.Printf("\nno outlier\n")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRow returns a comparison row with the given number of answers out of 10, all with the same latency
+func newTestRow(label string, successes int64, latency time.Duration) comparisonRow {
+	m := &measures{attempts: 10, successes: successes, latencies: stats.NewHistogram()}
+	for i := int64(0); i < successes; i++ {
+		m.latencies.Record(stats.Measure(latency))
+	}
+	return comparisonRow{label: label, measures: m}
+}
+
+func TestFindOutliers(t *testing.T) {
+	rows := []comparisonRow{
+		newTestRow("192.0.2.1", 10, 20*time.Millisecond),
+		newTestRow("192.0.2.2", 10, 22*time.Millisecond),
+		newTestRow("192.0.2.3", 10, 80*time.Millisecond),
+		newTestRow("192.0.2.4", 7, 21*time.Millisecond),
+		newTestRow("192.0.2.5", 0, 0),
+	}
+
+	reasons := findOutliers(rows)
+	if reasons[0] != "" || reasons[1] != "" {
+		t.Errorf("192.0.2.1 and 192.0.2.2 should not be outliers: %v", reasons)
+	}
+	if !strings.HasPrefix(reasons[2], "median latency") {
+		t.Errorf("192.0.2.3 should be flagged as slow, not %q", reasons[2])
+	}
+	if !strings.HasPrefix(reasons[3], "loss 30.0%") {
+		t.Errorf("192.0.2.4 should be flagged as lossy, not %q", reasons[3])
+	}
+	if reasons[4] != "no answer received" {
+		t.Errorf("192.0.2.5 should be flagged as unreachable, not %q", reasons[4])
+	}
+}
+
+func TestAddressLabel(t *testing.T) {
+	if label := addressLabel(&Config{AllAddresses: true, ConnTarget: "[2001:db8::1]:443"}); label != "2001:db8::1" {
+		t.Errorf("unexpected address label %q", label)
+	}
+	if label := addressLabel(&Config{ConnTarget: "192.0.2.1:443"}); label != "" {
+		t.Error("the address should only be labelled when all the addresses are pinged")
+	}
+}
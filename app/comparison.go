@@ -0,0 +1,68 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"time"
+)
+
+type comparisonRow struct {
+	label    string
+	measures *measures
+}
+
+// printComparison prints the statistics of several ping sessions side by side, followed by the average of some phases
+func printComparison(logger ConsoleLogger, title, header string, phases []stats.TimerType, rows []comparisonRow) {
+	width := len(header)
+	for _, row := range rows {
+		if len(row.label) > width {
+			width = len(row.label)
+		}
+	}
+
+	ms := func(d stats.Measure) float64 {
+		return d.ToFloat(time.Millisecond)
+	}
+
+	_, _ = logger.Printf("--- comparison of %s (ms) ---\n", title)
+	_, _ = logger.Printf("%-*s %8s %8s %7s %9s %9s %9s %9s %9s %9s", width, header, "sent", "received", "loss", "min", "avg", "p50", "p95", "p99", "max")
+	for _, phase := range phases {
+		_, _ = logger.Printf(" %9s", phase.String())
+	}
+	_, _ = logger.Printf("\n")
+
+	for _, row := range rows {
+		_, _ = logger.Printf("%-*s %8d %8d %6.1f%%", width, row.label, row.measures.attempts, row.measures.successes, row.measures.lossRate()*100)
+
+		if row.measures.successes > 0 {
+			ps := stats.PingStatsFromHistogram(row.measures.latencies)
+			_, _ = logger.Printf(" %9.3f %9.3f %9.3f %9.3f %9.3f %9.3f", ms(ps.Min), ms(ps.Average), ms(ps.P50), ms(ps.P95), ms(ps.P99), ms(ps.Max))
+		} else {
+			_, _ = logger.Printf(" %9s %9s %9s %9s %9s %9s", "-", "-", "-", "-", "-", "-")
+		}
+
+		for _, phase := range phases {
+			if h := row.measures.phases.Get(phase); h != nil {
+				_, _ = logger.Printf(" %9.3f", ms(h.Average()))
+			} else {
+				_, _ = logger.Printf(" %9s", "-")
+			}
+		}
+		_, _ = logger.Printf("\n")
+	}
+}
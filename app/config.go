@@ -17,9 +17,17 @@
 package app
 
 import (
+	"crypto/tls"
+	"regexp"
 	"time"
 )
 
+// Output formats supported by HTTPPing
+const (
+	OutputText  = "text"
+	OutputJSONL = "jsonl"
+)
+
 type pair struct {
 	Name  string
 	Value string
@@ -40,13 +48,42 @@ type Config struct {
 	Interval           time.Duration
 	Count              int64
 	Target             string
+	Targets            []string
 	Method             string
+	Body               []byte
+	BodyContentType    string
 	UserAgent          string
 	Wait               time.Duration
 	DisableKeepAlive   bool
 	LogLevel           int8
 	ConnTarget         string
+	UnixSocket         string
+	SourceAddress      string
+	Interface          string
+	TOS                int
+	SOMark             int
+	RcvBuf             int
+	SndBuf             int
+	CongestionControl  string
+	TCPNoDelay         *bool
+	Proxy              string
+	NoProxy            bool
 	NoCheckCertificate bool
+	ClientCert         string
+	ClientKey          string
+	ClientCertType     string
+	ClientCertPassword string
+	CACert             string
+	CAPath             string
+	TLSServerName      string
+	TLSMinVersion      uint16
+	TLSMaxVersion      uint16
+	CipherSuites       []uint16
+	Curves             []tls.CurveID
+	ALPN               []string
+	TLSInfo            bool
+	TLSResumption      bool
+	CertExpiryWarning  time.Duration
 	Cookies            []Cookie
 	Headers            []Header
 	Parameters         []Parameter
@@ -62,17 +99,36 @@ type Config struct {
 	HTTP3              bool
 	FullDNS            bool
 	DNSServer          string
+	DoHMethod          string
 	CacheDNSRequests   bool
+	DNSMinTTL          time.Duration
+	DNSMaxTTL          time.Duration
 	KeepCookies        bool
 	FollowRedirects    bool
 	Workers            int
 	Throughput         bool
 	ThroughputRefresh  time.Duration
 	TestVersion        bool
+	OutputFormat       string
+	Histogram          bool
+	PrometheusListen   string
+	CompareProtocols   bool
+	AllAddresses       bool
+	Rate               float64
+	MaxWorkers         int
+	Duration           time.Duration
+	Thresholds         Thresholds
+	ExpectStatus       []int
+	ExpectHeaders      []Header
+	ExpectBodyRegex    *regexp.Regexp
+	ExpectJSON         []*JSONExpectation
 }
 
 // RuntimeConfig defines the parameters which can be passed to NewPinger and NewWebClientBuilder
 type RuntimeConfig struct {
 	RedirectCallBack    func(url string)
 	ResolvedConnAddress string
+	TLSSessionCache     tls.ClientSessionCache
+	DNSBackend          BackendResolver
+	DNSCache            *DNSCache
 }
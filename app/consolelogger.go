@@ -19,6 +19,8 @@ package app
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"strings"
+	"sync"
 )
 
 type ConsoleLogger interface {
@@ -48,3 +50,49 @@ func (logger *consoleLoggerCmdImpl) Printf(format string, a ...any) (int, error)
 	logger.cmd.Printf(format, a...)
 	return 0, nil
 }
+
+func newConsoleDiscardLogger() ConsoleLogger {
+	return &consoleLoggerDiscardImpl{}
+}
+
+type consoleLoggerDiscardImpl struct {
+}
+
+func (logger *consoleLoggerDiscardImpl) Printf(_ string, _ ...any) (int, error) {
+	return 0, nil
+}
+
+// newConsolePrefixLogger builds a ConsoleLogger which adds a prefix at the beginning of every line, the lines are only
+// written once complete, so that the lines of several goroutines are not mixed
+func newConsolePrefixLogger(logger ConsoleLogger, prefix string) ConsoleLogger {
+	return &consoleLoggerPrefixImpl{logger: logger, prefix: prefix}
+}
+
+type consoleLoggerPrefixImpl struct {
+	mutex   sync.Mutex
+	logger  ConsoleLogger
+	prefix  string
+	pending strings.Builder // beginning of the current line, prefix included
+}
+
+func (logger *consoleLoggerPrefixImpl) Printf(format string, a ...any) (int, error) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	var lines strings.Builder
+	for _, c := range fmt.Sprintf(format, a...) {
+		if logger.pending.Len() == 0 && c != '\n' {
+			logger.pending.WriteString(logger.prefix)
+		}
+		logger.pending.WriteRune(c)
+		if c == '\n' {
+			lines.WriteString(logger.pending.String())
+			logger.pending.Reset()
+		}
+	}
+
+	if lines.Len() == 0 {
+		return 0, nil
+	}
+	return logger.logger.Printf("%s", lines.String())
+}
@@ -0,0 +1,54 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConsolePrefixLogger(t *testing.T) {
+	b := bytes.NewBufferString("")
+	logger := newConsolePrefixLogger(&consoleLoggerMock{b: b}, "[a] ")
+
+	// lines written piece by piece by several goroutines are not mixed
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = logger.Printf("x")
+				_, _ = logger.Printf("y\n")
+			}
+		}()
+	}
+	wg.Wait()
+	_, _ = logger.Printf("\n")
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 401 || lines[400] != "" {
+		t.Fatalf("400 lines and an empty one expected, got %d", len(lines))
+	}
+	for _, line := range lines[:400] {
+		if !strings.HasPrefix(line, "[a] ") || strings.Count(line, "[a] ") != 1 {
+			t.Fatalf("each line should have a single prefix: %q", line)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BackendResolver resolves names through a specific DNS server and transport
+type BackendResolver interface {
+	Resolve(host string, qtypes []uint16) (*dns.Msg, error)
+}
+
+// NewBackendResolver returns the backend resolving names through the DNS server of config when it is given by an
+// URL: https://dns.google/dns-query for DNS-over-HTTPS, tls://1.1.1.1 for DNS-over-TLS or quic://dns.example for
+// DNS-over-QUIC, it returns nil for plain DNS servers
+func NewBackendResolver(config *Config) (BackendResolver, error) {
+	if !strings.Contains(config.DNSServer, "://") {
+		return nil, nil
+	}
+
+	u, err := url.Parse(config.DNSServer)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid DNS server `%s', should be an URL such as https://dns.google/dns-query", config.DNSServer)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return NewDoHResolver(config.DNSServer, config.DoHMethod, config.Wait), nil
+	case "tls":
+		return NewDoTResolver(withDefaultPort(u, "853"), config.Wait), nil
+	case "quic":
+		return NewDoQResolver(withDefaultPort(u, "853"), config.Wait), nil
+	default:
+		return nil, fmt.Errorf("unsupported DNS server scheme `%s', should be https, tls or quic", u.Scheme)
+	}
+}
+
+// withDefaultPort returns the host:port address of an URL, with a default port
+func withDefaultPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// qtypesOf returns the types of the records to be queried to resolve the addresses of a network (ip, ip4 or ip6)
+func qtypesOf(network string) []uint16 {
+	switch network {
+	case "ip4":
+		return []uint16{dns.TypeA}
+	case "ip6":
+		return []uint16{dns.TypeAAAA}
+	default:
+		return []uint16{dns.TypeAAAA, dns.TypeA}
+	}
+}
+
+// resolveQtypes sends a query per type of record, as most servers do not support several questions in a query, the
+// answers are merged into a single message
+func resolveQtypes(exchange func(*dns.Msg) (*dns.Msg, error), host string, qtypes []uint16) (*dns.Msg, error) {
+	merged := new(dns.Msg)
+	for _, qtype := range qtypes {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(host), qtype)
+
+		answer, err := exchange(msg)
+		if err != nil {
+			return nil, err
+		}
+		merged.Question = append(merged.Question, msg.Question...)
+		merged.Answer = append(merged.Answer, answer.Answer...)
+		merged.Rcode = answer.Rcode
+	}
+	return merged, nil
+}
+
+// addressesOf returns the addresses (A and AAAA records) of the answer to a query
+func addressesOf(msg *dns.Msg) []net.IP {
+	var ips []net.IP
+	for _, a := range msg.Answer {
+		if ipv4, ok := a.(*dns.A); ok {
+			ips = append(ips, ipv4.A)
+		} else if ipv6, ok := a.(*dns.AAAA); ok {
+			ips = append(ips, ipv6.AAAA)
+		}
+	}
+	return ips
+}
+
+// ttlOf returns the lowest TTL of the records of the answer to a query
+func ttlOf(msg *dns.Msg) time.Duration {
+	var ttl uint32
+	for i, rr := range msg.Answer {
+		if i == 0 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+const dnsMessageType = "application/dns-message"
+
+// DoHResolver resolves names with DNS-over-HTTPS (RFC 8484), its connections to the server are reused
+type DoHResolver struct {
+	endpoint string
+	method   string
+	client   *http.Client
+}
+
+// NewDoHResolver builds a DNS-over-HTTPS resolver sending its queries with GET (by default) or POST requests
+func NewDoHResolver(endpoint, method string, timeout time.Duration) BackendResolver {
+	return newDoHResolver(endpoint, method, &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			ForceAttemptHTTP2: true,
+			MaxIdleConns:      10,
+			IdleConnTimeout:   90 * time.Second,
+		},
+	})
+}
+
+func newDoHResolver(endpoint, method string, client *http.Client) *DoHResolver {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return &DoHResolver{endpoint: endpoint, method: method, client: client}
+}
+
+func (dohResolver *DoHResolver) Resolve(host string, qtypes []uint16) (*dns.Msg, error) {
+	return resolveQtypes(dohResolver.exchange, host, qtypes)
+}
+
+func (dohResolver *DoHResolver) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	// the ID is 0 so that the answers can be cached by HTTP caches (RFC 8484, section 4.1)
+	msg.Id = 0
+	wire, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if dohResolver.method == http.MethodPost {
+		req, err = http.NewRequest(http.MethodPost, dohResolver.endpoint, bytes.NewReader(wire))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", dnsMessageType)
+	} else {
+		req, err = http.NewRequest(http.MethodGet, dohResolver.endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		q := req.URL.Query()
+		q.Set("dns", base64.RawURLEncoding.EncodeToString(wire))
+		req.URL.RawQuery = q.Encode()
+	}
+	req.Header.Set("Accept", dnsMessageType)
+
+	res, err := dohResolver.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server answered with status code %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+
+	answer := new(dns.Msg)
+	if err = answer.Unpack(body); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fever.ch/http-ping/stats"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dnsTestHandler answers the A queries with 127.0.0.1, and the other ones with an empty answer
+func dnsTestHandler(query *dns.Msg) *dns.Msg {
+	answer := new(dns.Msg)
+	answer.SetReply(query)
+	if q := query.Question[0]; q.Qtype == dns.TypeA {
+		answer.Answer = append(answer.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.IPv4(127, 0, 0, 1),
+		})
+	}
+	return answer
+}
+
+// newDoHTestServer starts a DNS-over-HTTPS server, the methods of the queries are sent to methods
+func newDoHTestServer(t *testing.T, methods chan<- string) *httptest.Server {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wire []byte
+		if r.Method == http.MethodPost {
+			wire, _ = io.ReadAll(r.Body)
+		} else {
+			wire, _ = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		}
+
+		query := new(dns.Msg)
+		if err := query.Unpack(wire); err != nil || len(query.Question) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		methods <- r.Method
+
+		packed, _ := dnsTestHandler(query).Pack()
+		w.Header().Set("Content-Type", dnsMessageType)
+		_, _ = w.Write(packed)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestDoHResolver(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		methods := make(chan string, 2)
+		ts := newDoHTestServer(t, methods)
+
+		msg, err := newDoHResolver(ts.URL+"/dns-query", method, ts.Client()).Resolve("www.example.com", qtypesOf("ip"))
+		if err != nil {
+			t.Fatalf("%s: %s", method, err)
+		}
+		if ips := addressesOf(msg); len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Fatalf("%s: unexpected answer %v", method, msg.Answer)
+		}
+		if m := <-methods; m != method {
+			t.Fatalf("query sent with %s instead of %s", m, method)
+		}
+	}
+}
+
+func TestDoHResolution(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	doh := newDoHTestServer(t, make(chan string, 2))
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	config := &Config{Target: "http://service.test:" + port + "/", IPProtocol: "ip4", DNSServer: doh.URL}
+	webClient, _ := NewWebClientBuilder(config, &RuntimeConfig{DNSBackend: newDoHResolver(doh.URL, http.MethodGet, doh.Client())}, nil)
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+
+	if measure.IsFailure {
+		t.Fatalf("target should have been resolved with DoH: %s", measure.FailureCause)
+	}
+	if !measure.MeasuresCollection.Get(stats.DNS).IsValid() {
+		t.Error("the DoH query should be reported as the DNS phase")
+	}
+}
+
+// newTestTLSConfigs returns the TLS configurations of a server and of a client trusting it, for 127.0.0.1
+func newTestTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	ts := httptest.NewTLSServer(nil)
+	t.Cleanup(ts.Close)
+	return ts.TLS.Clone(), &tls.Config{RootCAs: ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs, ServerName: "127.0.0.1"}
+}
+
+func TestDoTResolver(t *testing.T) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{Listener: listener, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		_ = w.WriteMsg(dnsTestHandler(r))
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	defer server.Shutdown()
+
+	resolver := newDoTResolver(listener.Addr().String(), clientTLS, 5*time.Second)
+	// the connection is reused by the queries
+	for i := 0; i < 2; i++ {
+		msg, err := resolver.Resolve("www.example.com", qtypesOf("ip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ips := addressesOf(msg); len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Fatalf("unexpected answer %v", msg.Answer)
+		}
+	}
+}
+
+// newDoQTestServer starts a DNS-over-QUIC server, when closeFirst is set the connection of the first query is closed
+// before it is answered, as an idle connection would be
+func newDoQTestServer(t *testing.T, serverTLS *tls.Config, closeFirst bool) string {
+	serverTLS.NextProtos = []string{"doq"}
+
+	listener, err := quic.ListenAddr("127.0.0.1:0", serverTLS, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var closeOnce sync.Once
+	serve := func(conn quic.Connection) {
+		for {
+			stream, err := conn.AcceptStream(context.Background())
+			if err != nil {
+				return
+			}
+			var length uint16
+			_ = binary.Read(stream, binary.BigEndian, &length)
+			wire := make([]byte, length)
+			_, _ = io.ReadFull(stream, wire)
+
+			closed := false
+			if closeFirst {
+				closeOnce.Do(func() {
+					_ = conn.CloseWithError(0, "idle")
+					closed = true
+				})
+			}
+			if closed {
+				return
+			}
+
+			query := new(dns.Msg)
+			if query.Unpack(wire) != nil || query.Id != 0 {
+				stream.CancelWrite(0)
+				continue
+			}
+			packed, _ := dnsTestHandler(query).Pack()
+			_, _ = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+			_, _ = stream.Write(packed)
+			_ = stream.Close()
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDoQResolver(t *testing.T) {
+	for _, closeFirst := range []bool{false, true} {
+		serverTLS, clientTLS := newTestTLSConfigs(t)
+
+		resolver := newDoQResolver(newDoQTestServer(t, serverTLS, closeFirst), clientTLS, 5*time.Second)
+		for i := 0; i < 2; i++ {
+			msg, err := resolver.Resolve("www.example.com", qtypesOf("ip"))
+			if err != nil {
+				t.Fatalf("closed connection %t: %s", closeFirst, err)
+			}
+			if ips := addressesOf(msg); len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+				t.Fatalf("unexpected answer %v", msg.Answer)
+			}
+		}
+	}
+}
+
+func TestNewBackendResolver(t *testing.T) {
+	for _, server := range []string{"", "8.8.8.8", "2001:4860:4860::8888", "dns.google"} {
+		if backend, err := NewBackendResolver(&Config{DNSServer: server}); backend != nil || err != nil {
+			t.Errorf("`%s' should be used as a plain DNS server", server)
+		}
+	}
+	if backend, err := NewBackendResolver(&Config{DNSServer: "https://dns.google/dns-query"}); err != nil || backend == nil {
+		t.Error("https URLs should be resolved with DoH")
+	}
+	if backend, err := NewBackendResolver(&Config{DNSServer: "tls://1.1.1.1"}); err != nil || backend.(*DoTResolver).address != "1.1.1.1:853" {
+		t.Error("tls URLs should be resolved with DoT, on port 853 by default")
+	}
+	if backend, err := NewBackendResolver(&Config{DNSServer: "quic://dns.example:8853"}); err != nil || backend.(*DoQResolver).address != "dns.example:8853" {
+		t.Error("quic URLs should be resolved with DoQ")
+	}
+	if _, err := NewBackendResolver(&Config{DNSServer: "ftp://dns.google"}); err == nil {
+		t.Error("unsupported schemes should be rejected")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolution is the outcome of the resolution of a name: its addresses, the TTL of the answer and the server that
+// answered
+type resolution struct {
+	addrs  []net.IP
+	ttl    time.Duration
+	server string
+}
+
+// addrsString returns the addresses of a resolution, sorted so that answers rotated by the servers are identical
+func (r resolution) addrsString() string {
+	addrs := make([]string, len(r.addrs))
+	for i, ip := range r.addrs {
+		addrs[i] = ip.String()
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, ", ")
+}
+
+// DNSChange is a change of the addresses a name resolves to
+type DNSChange struct {
+	Time     time.Time
+	Host     string
+	From, To string
+}
+
+// DNSCache caches the resolutions for the TTL of their answers, clamped between minTTL and maxTTL (0 for no limit),
+// and records the changes of the answers, it is shared by the resolvers of a session
+type DNSCache struct {
+	mu             sync.Mutex
+	minTTL, maxTTL time.Duration
+	entries        map[string]dnsCacheEntry
+	hits, misses   int64
+	changes        []DNSChange
+	now            func() time.Time
+}
+
+type dnsCacheEntry struct {
+	resolution resolution
+	expiry     time.Time
+}
+
+// NewDNSCache builds a DNS cache, the TTLs of the answers are clamped between minTTL and maxTTL (0 for no limit)
+func NewDNSCache(minTTL, maxTTL time.Duration) *DNSCache {
+	return &DNSCache{
+		minTTL:  minTTL,
+		maxTTL:  maxTTL,
+		entries: make(map[string]dnsCacheEntry),
+		now:     time.Now,
+	}
+}
+
+// get returns the resolution of host, if it has not expired yet
+func (cache *DNSCache) get(host string) (resolution, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if entry, ok := cache.entries[host]; ok && cache.now().Before(entry.expiry) {
+		cache.hits++
+		return entry.resolution, true
+	}
+	cache.misses++
+	return resolution{}, false
+}
+
+// put stores a fresh resolution of host, a change of its addresses is recorded
+func (cache *DNSCache) put(host string, r resolution) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := cache.now()
+	if entry, ok := cache.entries[host]; ok {
+		if from, to := entry.resolution.addrsString(), r.addrsString(); from != to {
+			cache.changes = append(cache.changes, DNSChange{Time: now, Host: host, From: from, To: to})
+		}
+	}
+
+	ttl := max(r.ttl, cache.minTTL)
+	if cache.maxTTL > 0 {
+		ttl = min(ttl, cache.maxTTL)
+	}
+	cache.entries[host] = dnsCacheEntry{resolution: r, expiry: now.Add(ttl)}
+}
+
+// stats returns the number of hits and misses of the cache, and the changes of the answers
+func (cache *DNSCache) stats() (int64, int64, []DNSChange) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.hits, cache.misses, append([]DNSChange{}, cache.changes...)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDNSCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewDNSCache(time.Minute, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.put("short", resolution{addrs: []net.IP{net.IPv4(192, 0, 2, 1)}, ttl: time.Second})
+	cache.put("long", resolution{addrs: []net.IP{net.IPv4(192, 0, 2, 2)}, ttl: 24 * time.Hour})
+
+	now = now.Add(30 * time.Second)
+	if _, ok := cache.get("short"); !ok {
+		t.Error("the TTL should have been raised to the minimum")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := cache.get("long"); ok {
+		t.Error("the TTL should have been lowered to the maximum")
+	}
+
+	if hits, misses, _ := cache.stats(); hits != 1 || misses != 1 {
+		t.Errorf("1 hit and 1 miss expected, got %d and %d", hits, misses)
+	}
+}
+
+func TestDNSCacheChanges(t *testing.T) {
+	cache := NewDNSCache(0, 0)
+
+	a, b := net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2)
+	cache.put("www.example.com", resolution{addrs: []net.IP{a, b}})
+	// rotated answers are not changes
+	cache.put("www.example.com", resolution{addrs: []net.IP{b, a}})
+	cache.put("www.example.com", resolution{addrs: []net.IP{b}})
+
+	_, _, changes := cache.stats()
+	if len(changes) != 1 || changes[0].From != "192.0.2.1, 192.0.2.2" || changes[0].To != "192.0.2.2" {
+		t.Fatalf("a single change expected, got %v", changes)
+	}
+}
+
+func TestDNSCacheConcurrency(t *testing.T) {
+	cache := NewDNSCache(0, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, ok := cache.get("www.example.com"); !ok {
+					cache.put("www.example.com", resolution{addrs: []net.IP{net.IPv4(192, 0, 2, 1)}, ttl: time.Minute})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits, misses, _ := cache.stats(); hits+misses != 800 {
+		t.Errorf("800 lookups expected, got %d", hits+misses)
+	}
+}
+
+func TestDNSCacheHostsFile(t *testing.T) {
+	resolver := newResolver(&Config{CacheDNSRequests: true}, nil)
+	resolver.stub = &stubResolver{hosts: map[string][]net.IP{"intranet": {net.IPv4(192, 0, 2, 1)}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.resolve("intranet"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hits, misses, _ := resolver.cache.stats(); hits != 2 || misses != 1 {
+		t.Errorf("the addresses of the hosts file should be cached, got %d hits and %d misses", hits, misses)
+	}
+}
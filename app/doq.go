@@ -0,0 +1,148 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DoQResolver resolves names with DNS-over-QUIC (RFC 9250), each query is sent on its own stream of a connection which
+// is reused
+type DoQResolver struct {
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	mutex sync.Mutex
+	conn  quic.Connection
+}
+
+// NewDoQResolver builds a DNS-over-QUIC resolver, address is in the form host:port
+func NewDoQResolver(address string, timeout time.Duration) BackendResolver {
+	host, _, _ := net.SplitHostPort(address)
+	return newDoQResolver(address, &tls.Config{ServerName: host}, timeout)
+}
+
+func newDoQResolver(address string, tlsConfig *tls.Config, timeout time.Duration) *DoQResolver {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{"doq"}
+	return &DoQResolver{address: address, tlsConfig: tlsConfig, timeout: timeout}
+}
+
+func (doqResolver *DoQResolver) Resolve(host string, qtypes []uint16) (*dns.Msg, error) {
+	return resolveQtypes(doqResolver.exchange, host, qtypes)
+}
+
+// connection returns the connection to the server, a new one if the previous one was closed
+func (doqResolver *DoQResolver) connection(ctx context.Context) (quic.Connection, error) {
+	doqResolver.mutex.Lock()
+	defer doqResolver.mutex.Unlock()
+
+	if doqResolver.conn != nil && doqResolver.conn.Context().Err() == nil {
+		return doqResolver.conn, nil
+	}
+
+	conn, err := quic.DialAddr(ctx, doqResolver.address, doqResolver.tlsConfig, &quic.Config{KeepAlivePeriod: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	doqResolver.conn = conn
+	return conn, nil
+}
+
+// drop closes a connection which failed, a new one is established for the next query
+func (doqResolver *DoQResolver) drop(conn quic.Connection) {
+	doqResolver.mutex.Lock()
+	defer doqResolver.mutex.Unlock()
+
+	if doqResolver.conn == conn {
+		doqResolver.conn = nil
+	}
+	_ = conn.CloseWithError(0, "")
+}
+
+func (doqResolver *DoQResolver) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doqResolver.timeout)
+	defer cancel()
+
+	// the ID is 0 since streams already identify the queries (RFC 9250, section 4.2.1)
+	msg.Id = 0
+	wire, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	// the server might have closed an idle connection, the query is then sent again on a new one
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn quic.Connection
+		if conn, err = doqResolver.connection(ctx); err != nil {
+			return nil, err
+		}
+
+		var answer *dns.Msg
+		if answer, err = doqResolver.exchangeOn(ctx, conn, wire); err == nil {
+			return answer, nil
+		}
+		doqResolver.drop(conn)
+	}
+	return nil, err
+}
+
+// exchangeOn sends a query on a new stream of conn and reads its answer
+func (doqResolver *DoQResolver) exchangeOn(ctx context.Context, conn quic.Connection, wire []byte) (*dns.Msg, error) {
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CancelRead(0)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+
+	// messages are prefixed with their length, the end of the query is signaled by closing the sending side
+	if _, err = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(wire)))); err == nil {
+		_, err = stream.Write(wire)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = stream.Close()
+
+	var length uint16
+	if err = binary.Read(stream, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(stream, buf); err != nil {
+		return nil, err
+	}
+
+	answer := new(dns.Msg)
+	if err = answer.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"crypto/tls"
+	"github.com/miekg/dns"
+	"net"
+	"sync"
+	"time"
+)
+
+// DoTResolver resolves names with DNS-over-TLS (RFC 7858), its connection to the server is reused
+type DoTResolver struct {
+	address string
+	client  *dns.Client
+
+	mutex sync.Mutex
+	conn  *dns.Conn
+}
+
+// NewDoTResolver builds a DNS-over-TLS resolver, address is in the form host:port
+func NewDoTResolver(address string, timeout time.Duration) BackendResolver {
+	host, _, _ := net.SplitHostPort(address)
+	return newDoTResolver(address, &tls.Config{ServerName: host}, timeout)
+}
+
+func newDoTResolver(address string, tlsConfig *tls.Config, timeout time.Duration) *DoTResolver {
+	return &DoTResolver{
+		address: address,
+		client:  &dns.Client{Net: "tcp-tls", TLSConfig: tlsConfig, Timeout: timeout},
+	}
+}
+
+func (dotResolver *DoTResolver) Resolve(host string, qtypes []uint16) (*dns.Msg, error) {
+	return resolveQtypes(dotResolver.exchange, host, qtypes)
+}
+
+func (dotResolver *DoTResolver) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	dotResolver.mutex.Lock()
+	defer dotResolver.mutex.Unlock()
+
+	var err error
+	// the server might have closed an idle connection, the query is then sent again on a new one
+	for attempt := 0; attempt < 2; attempt++ {
+		if dotResolver.conn == nil {
+			if dotResolver.conn, err = dotResolver.client.Dial(dotResolver.address); err != nil {
+				return nil, err
+			}
+		}
+
+		var answer *dns.Msg
+		if answer, _, err = dotResolver.client.ExchangeWithConn(msg, dotResolver.conn); err == nil {
+			return answer, nil
+		}
+		_ = dotResolver.conn.Close()
+		dotResolver.conn = nil
+	}
+	return nil, err
+}
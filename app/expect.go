@@ -0,0 +1,262 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxCapturedBody is the maximal number of bytes of a response body kept to check expectations, the rest is discarded
+// and JSON expectations cannot be checked
+const maxCapturedBody = 1 << 20
+
+// cappedBuffer keeps the first bytes written to it and discards the others, recording that it did so
+type cappedBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - len(b.data)
+	if room > len(p) {
+		room = len(p)
+	}
+	if room > 0 {
+		b.data = append(b.data, p[:room]...)
+	}
+	b.truncated = b.truncated || room < len(p)
+	return len(p), nil
+}
+
+// needsBody returns true if some expectations are about the body of the responses
+func (config *Config) needsBody() bool {
+	return config.ExpectBodyRegex != nil || len(config.ExpectJSON) > 0
+}
+
+// checkExpectations checks a response against the expectations of config, it returns the cause of the first mismatch
+// or an empty string if the response is as expected; truncated tells whether body is only the beginning of the payload
+func checkExpectations(config *Config, res *http.Response, body []byte, truncated bool) string {
+	if len(config.ExpectStatus) > 0 {
+		expected := false
+		for _, code := range config.ExpectStatus {
+			expected = expected || code == res.StatusCode
+		}
+		if !expected {
+			return fmt.Sprintf("Unexpected status code %d", res.StatusCode)
+		}
+	}
+
+	for _, header := range config.ExpectHeaders {
+		values := res.Header.Values(header.Name)
+		if len(values) == 0 {
+			return fmt.Sprintf("Missing header %s", header.Name)
+		}
+		found := false
+		for _, value := range values {
+			found = found || strings.Contains(value, header.Value)
+		}
+		if !found {
+			return fmt.Sprintf("Header %s is `%s', expected `%s'", header.Name, strings.Join(values, ", "), header.Value)
+		}
+	}
+
+	if config.ExpectBodyRegex != nil && !config.ExpectBodyRegex.Match(body) {
+		return fmt.Sprintf("Body does not match `%s'", config.ExpectBodyRegex)
+	}
+
+	// a truncated document cannot be decoded
+	if len(config.ExpectJSON) > 0 && truncated {
+		return "Body too large for JSON expectations"
+	}
+
+	for _, expectation := range config.ExpectJSON {
+		if cause := expectation.check(body); cause != "" {
+			return cause
+		}
+	}
+
+	return ""
+}
+
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// JSONExpectation is an expectation about a value of a JSON document, in the form `$.path[0].to.value == "literal"`.
+// The operator can be == or !=, without operator the value only has to exist.
+type JSONExpectation struct {
+	expression string
+	path       []jsonPathStep
+	operator   string
+	literal    any
+}
+
+// ParseJSONExpectation parses an expectation about a value of a JSON document
+func ParseJSONExpectation(expression string) (*JSONExpectation, error) {
+	e := &JSONExpectation{expression: expression}
+
+	path := expression
+	if i := operatorIndex(expression); i >= 0 {
+		path = expression[:i]
+		e.operator = expression[i : i+2]
+		literal := strings.TrimSpace(expression[i+2:])
+		if err := json.Unmarshal([]byte(literal), &e.literal); err != nil {
+			return nil, fmt.Errorf("invalid JSON literal `%s'", literal)
+		}
+	}
+
+	steps, err := parseJSONPath(strings.TrimSpace(path))
+	if err != nil {
+		return nil, err
+	}
+	e.path = steps
+	return e, nil
+}
+
+// operatorIndex returns the position of the first == or != of an expression outside of the subscripts and quoted
+// keys of the path, or -1 if there is none
+func operatorIndex(expression string) int {
+	inBracket, inQuote := false, false
+	for i := 0; i < len(expression); i++ {
+		c := expression[i]
+		switch {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case c == '"':
+			inQuote = inBracket
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case (c == '=' || c == '!') && !inBracket && i+1 < len(expression) && expression[i+1] == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSON path `%s' should start with $", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				return nil, fmt.Errorf("empty key in JSON path `%s'", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[1:end]})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in JSON path `%s'", path)
+			}
+			inside := rest[1:end]
+			if key, err := strconv.Unquote(inside); err == nil {
+				steps = append(steps, jsonPathStep{key: key})
+			} else if index, err := strconv.Atoi(inside); err == nil && index >= 0 {
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("invalid subscript `%s' in JSON path `%s'", inside, path)
+			}
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected character `%c' in JSON path `%s'", rest[0], path)
+		}
+	}
+	return steps, nil
+}
+
+func (e *JSONExpectation) String() string {
+	return e.expression
+}
+
+// lookup returns the value at the path of the expectation in a decoded JSON document
+func (e *JSONExpectation) lookup(document any) (any, bool) {
+	value := document
+	for _, step := range e.path {
+		if step.isIndex {
+			array, ok := value.([]any)
+			if !ok || step.index >= len(array) {
+				return nil, false
+			}
+			value = array[step.index]
+		} else {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[step.key]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return value, true
+}
+
+// check returns the reason why a body does not meet the expectation, or an empty string if it does
+func (e *JSONExpectation) check(body []byte) string {
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return "Body is not a valid JSON document"
+	}
+
+	value, found := e.lookup(document)
+	if !found {
+		return fmt.Sprintf("JSON value `%s' not found", e.expression)
+	}
+
+	switch e.operator {
+	case "==":
+		if !reflect.DeepEqual(value, e.literal) {
+			return fmt.Sprintf("JSON expectation `%s' not met, value is %s", e.expression, jsonString(value))
+		}
+	case "!=":
+		if reflect.DeepEqual(value, e.literal) {
+			return fmt.Sprintf("JSON expectation `%s' not met", e.expression)
+		}
+	}
+	return ""
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestJSONExpectation(t *testing.T) {
+	body := []byte(`{"status": "ok", "items": [{"id": 1}, {"id": 2}], "a.b": true, "a!": 1, "k!=v": "x"}`)
+
+	cases := []struct {
+		expression string
+		met        bool
+	}{
+		{`$.status == "ok"`, true},
+		{`$.status != "ok"`, false},
+		{`$.status=="down"`, false},
+		{`$.items[1].id == 2`, true},
+		{`$.items[2].id == 3`, false},
+		{`$["a.b"] == true`, true},
+		{`$["a!"] == 1`, true},
+		{`$["a!"] != 1`, false},
+		{`$["k!=v"]`, true},
+		{`$["k!=v"] == "x"`, true},
+		{`$["k\"!="] == "x"`, false},
+		{`$.items`, true},
+		{`$.missing`, false},
+	}
+
+	for _, c := range cases {
+		e, err := ParseJSONExpectation(c.expression)
+		if err != nil {
+			t.Fatalf("%s: %s", c.expression, err)
+		}
+		if met := e.check(body) == ""; met != c.met {
+			t.Errorf("%s: expectation met=%t, wanted %t", c.expression, met, c.met)
+		}
+	}
+
+	for _, expression := range []string{`status == "ok"`, `$.status == ok`, `$.items[x]`, `$..status`} {
+		if _, err := ParseJSONExpectation(expression); err == nil {
+			t.Errorf("%s: should not be parsed", expression)
+		}
+	}
+}
+
+func TestCheckExpectations(t *testing.T) {
+	res := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}}}
+	body := []byte("<h1>Down for maintenance</h1>")
+
+	if cause := checkExpectations(&Config{ExpectStatus: []int{200, 204}}, res, body, false); cause != "" {
+		t.Errorf("unexpected failure: %s", cause)
+	}
+	if cause := checkExpectations(&Config{ExpectStatus: []int{204}}, res, body, false); !strings.Contains(cause, "status code 200") {
+		t.Errorf("unexpected cause: %s", cause)
+	}
+	if cause := checkExpectations(&Config{ExpectHeaders: []Header{{Name: "Content-Type", Value: "application/json"}}}, res, body, false); !strings.Contains(cause, "Content-Type") {
+		t.Errorf("unexpected cause: %s", cause)
+	}
+	if cause := checkExpectations(&Config{ExpectBodyRegex: regexp.MustCompile("Welcome")}, res, body, false); !strings.Contains(cause, "Welcome") {
+		t.Errorf("unexpected cause: %s", cause)
+	}
+
+	e, _ := ParseJSONExpectation("$.status")
+	if cause := checkExpectations(&Config{ExpectJSON: []*JSONExpectation{e}}, res, []byte(`{"status": `), true); !strings.Contains(cause, "too large") {
+		t.Errorf("unexpected cause: %s", cause)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 4}
+	if n, _ := b.Write([]byte("abc")); n != 3 || b.truncated {
+		t.Fatal("all the bytes should be accepted")
+	}
+	if n, _ := b.Write([]byte("def")); n != 3 || string(b.data) != "abcd" {
+		t.Fatalf("only the first bytes should be kept, got %s", b.data)
+	}
+	if !b.truncated {
+		t.Fatal("the buffer should be marked as truncated")
+	}
+}
@@ -29,6 +29,11 @@ import (
 )
 
 func newHTTP3RoundTripper(config *Config, runtimeConfig *RuntimeConfig, w *webClientImpl) (http.RoundTripper, error) {
+	tlsConfig, err := newTLSConfig(config, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	if config.Method == http.MethodGet {
 		config.Method = http3.MethodGet0RTT
 	}
@@ -42,33 +47,42 @@ func newHTTP3RoundTripper(config *Config, runtimeConfig *RuntimeConfig, w *webCl
 
 			traceDNSStart(trace, addr)
 
-			connAddr, e := w.resolver.resolveConn(addr)
-
-			if e != nil {
-				return nil, e
+			// a forced connection target is not resolved
+			connAddr, dnsServer := config.ConnTarget, ""
+			if connAddr == "" {
+				var e error
+				if connAddr, dnsServer, e = w.resolver.resolveConn(addr); e != nil {
+					return nil, e
+				}
 			}
 			runtimeConfig.ResolvedConnAddress = connAddr
+			if measureContext := measureContextFrom(ctx); measureContext != nil {
+				measureContext.dnsServer = dnsServer
+			}
 
 			traceDNSDone(trace, []net.IPAddr{})
 
 			traceTLSHandshakeStart(trace)
 
-			dae, err := quic.DialAddrEarly(ctx, connAddr, tlsCfg, cfg)
+			dae, err := dialQUIC(ctx, config, connAddr, tlsCfg, cfg)
 			if err != nil {
 				return nil, err
 			}
 
-			traceTLSHandshakeDone(trace, tls.ConnectionState{})
+			traceTLSHandshakeDone(trace, dae.ConnectionState().TLS)
+
+			// whether 0-RTT was accepted is only known once the handshake is complete, after the response
+			if measureContext := measureContextFrom(ctx); measureContext != nil {
+				measureContext.quicConn = dae
+			}
 
 			traceGotConn(trace, httptrace.GotConnInfo{Conn: connAdapter{remoteAddr: dae.RemoteAddr()}})
 
 			return wrapEarlyConnection(dae, w), err
 		},
 
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.NoCheckCertificate,
-		},
-		QUICConfig: &(quic.Config{}),
+		TLSClientConfig: tlsConfig,
+		QUICConfig:      &(quic.Config{}),
 	}, nil
 }
 
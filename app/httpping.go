@@ -17,6 +17,8 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
 	"fever.ch/http-ping/stats"
 	"os"
 	"os/signal"
@@ -75,8 +77,12 @@ func (h *httpPingTestVersion) checkHTTP(prep func(*Config)) <-chan string {
 		prep(&configCopy)
 
 		rc := RuntimeConfig{}
-		wc, _ := newWebClient(&configCopy, &rc, h.logger)
-		m := wc.DoMeasure(false)
+		wc, err := newWebClient(&configCopy, &rc, h.logger)
+		if err != nil {
+			r <- "\u001B[31m✗\u001B[0m " + err.Error()
+			return
+		}
+		m := wc.DoMeasure(context.Background(), false)
 
 		http3Advertisement := ""
 
@@ -97,21 +103,97 @@ func (h *httpPingTestVersion) checkHTTP(prep func(*Config)) <-chan string {
 // NewHTTPPing builds a new instance of HTTPPing or error if something goes wrong
 func NewHTTPPing(config *Config, consoleLogger ConsoleLogger) (HTTPPing, error) {
 
+	var exporter *prometheusExporter
+	if config.PrometheusListen != "" {
+		var err error
+		if exporter, err = startPrometheusExporter(config.PrometheusListen); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.CompareProtocols {
+		return newHTTPPingProtocols(config, consoleLogger, exporter)
+	}
+
+	if config.AllAddresses {
+		return newHTTPPingAddresses(config, consoleLogger, exporter)
+	}
+
+	if len(config.Targets) > 1 && !config.TestVersion {
+		return newHTTPPingMulti(config, consoleLogger, exporter)
+	}
+
+	pinger, logger, err := newPingSession(config, consoleLogger, exporter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TestVersion {
+		return &httpPingTestVersion{
+			baseConfig: config,
+			logger:     newStandardLogger(config, consoleLogger, pinger),
+		}, nil
+	}
+
+	return &httpPingImpl{
+		config: config,
+		pinger: pinger,
+		logger: logger,
+	}, nil
+}
+
+// protocolLabel returns the HTTP protocol enforced in config, or "auto"
+func protocolLabel(config *Config) string {
+	if config.HTTP1 {
+		return "h1"
+	} else if config.HTTP2 {
+		return "h2"
+	} else if config.HTTP3 {
+		return "h3"
+	}
+	return "auto"
+}
+
+// newPingSession builds the pinger of the target defined in config and the logger of its measures
+func newPingSession(config *Config, consoleLogger ConsoleLogger, exporter *prometheusExporter) (Pinger, PingLogger, error) {
+
 	runtimeConfig := &RuntimeConfig{
 		RedirectCallBack: func(url string) {
 			_, _ = consoleLogger.Printf("   ─→     redirected to %s\n", url)
 		},
 	}
 
-	pinger, err := NewPinger(config, runtimeConfig, consoleLogger)
+	dnsBackend, err := NewBackendResolver(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	runtimeConfig.DNSBackend = dnsBackend
+	runtimeConfig.DNSCache = NewDNSCache(config.DNSMinTTL, config.DNSMaxTTL)
+
+	// the sessions are shared by all the workers, so that every new connection can resume one
+	if config.TLSResumption {
+		runtimeConfig.TLSSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	// in machine-readable mode, the informative messages of the web client would corrupt the output
+	pingerConsoleLogger := consoleLogger
+	if config.OutputFormat == OutputJSONL {
+		runtimeConfig.RedirectCallBack = nil
+		pingerConsoleLogger = newConsoleDiscardLogger()
+	}
+
+	pinger, err := NewPinger(config, runtimeConfig, pingerConsoleLogger)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var logger PingLogger
 
-	if config.LogLevel == 0 {
+	if config.OutputFormat == OutputJSONL {
+		logger = newJSONLogger(config, consoleLogger, pinger)
+	} else if config.LogLevel == 0 {
 		logger = newQuietLogger(config, consoleLogger, pinger)
 	} else if config.LogLevel == 2 {
 		logger = newVerboseLogger(config, consoleLogger, pinger)
@@ -119,18 +201,27 @@ func NewHTTPPing(config *Config, consoleLogger ConsoleLogger) (HTTPPing, error)
 		logger = newStandardLogger(config, consoleLogger, pinger)
 	}
 
-	if config.TestVersion {
-		return &httpPingTestVersion{
-			baseConfig: config,
-			logger:     newStandardLogger(config, consoleLogger, pinger),
-		}, nil
+	logger.getMeasures().dnsCache = runtimeConfig.DNSCache
+
+	if exporter != nil {
+		logger = newPrometheusLogger(logger, exporter, pinger.URL(), protocolLabel(config), addressLabel(config))
 	}
 
-	return &httpPingImpl{
-		config: config,
-		pinger: pinger,
-		logger: logger,
-	}, nil
+	return pinger, logger, nil
+}
+
+// runContext returns the context of a run, it is done when the run is interrupted or when its duration is elapsed
+func runContext(config *Config) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if config.Duration <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
 }
 
 // Run does start of the application logic, returns an error if something goes wrong, nil otherwise
@@ -138,13 +229,13 @@ func (httpPingImpl *httpPingImpl) Run() error {
 
 	config := httpPingImpl.config
 
-	ic := make(chan os.Signal, 1)
-
-	signal.Notify(ic, os.Interrupt)
+	ctx, cancel := runContext(config)
+	defer cancel()
 
 	_, _ = httpPingImpl.logger.Printf("HTTP-PING %s %s\n\n", httpPingImpl.pinger.URL(), config.Method)
 
-	measuresChannel := httpPingImpl.pinger.Ping()
+	// once ctx is done, in-flight requests are aborted and the measures channel gets closed
+	measuresChannel := httpPingImpl.pinger.Ping(ctx)
 
 	tickerChan := make(<-chan time.Time)
 	tpuStarted := false
@@ -169,7 +260,9 @@ func (httpPingImpl *httpPingImpl) Run() error {
 					first = false
 				}
 
+				normalizePhases(measure)
 				httpPingImpl.logger.onMeasure(measure)
+				reportTLSInfo(config, httpPingImpl.logger, measure)
 				if config.Throughput && !tpuStarted {
 					throughputMeasurer.Measure()
 					tickerChan = (time.NewTicker(config.ThroughputRefresh)).C
@@ -181,9 +274,6 @@ func (httpPingImpl *httpPingImpl) Run() error {
 					httpPingImpl.logger.bell()
 				}
 			}
-
-		case <-ic:
-			loop = false
 		}
 	}
 
@@ -191,5 +281,9 @@ func (httpPingImpl *httpPingImpl) Run() error {
 	if httpPingImpl.config.Throughput {
 		httpPingImpl.logger.onThroughputClose()
 	}
+
+	if breaches := evaluateThresholds(config, httpPingImpl.logger); len(breaches) > 0 {
+		return &ThresholdError{Breaches: breaches}
+	}
 	return nil
 }
@@ -18,6 +18,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"fever.ch/http-ping/stats"
 	"fmt"
 	"io"
@@ -52,7 +53,7 @@ func (pingerMock *PingerMock) URL() string {
 	return "https://www.google.com"
 }
 
-func (pingerMock *PingerMock) Ping() <-chan *HTTPMeasure {
+func (pingerMock *PingerMock) Ping(_ context.Context) <-chan *HTTPMeasure {
 	measures := make(chan *HTTPMeasure)
 
 	go func() {
@@ -0,0 +1,229 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// pingSession is the pinging of one target among others, with its own statistics
+type pingSession struct {
+	label              string
+	pinger             Pinger
+	logger             PingLogger
+	throughputMeasurer *throughputMeasurer
+}
+
+type sessionMeasure struct {
+	session *pingSession
+	measure *HTTPMeasure
+}
+
+// httpPingMulti pings several targets concurrently, and compares them at the end
+type httpPingMulti struct {
+	config        *Config
+	consoleLogger ConsoleLogger
+	sessions      []*pingSession
+	title         string
+	header        string
+	phases        []stats.TimerType
+	flagOutliers  bool
+}
+
+func newHTTPPingMulti(config *Config, consoleLogger ConsoleLogger, exporter *prometheusExporter) (HTTPPing, error) {
+	h := &httpPingMulti{config: config, consoleLogger: consoleLogger, title: "targets", header: "target"}
+
+	for _, target := range config.Targets {
+		configCopy := *config
+		configCopy.Target = target
+		configCopy.Targets = nil
+
+		if err := h.addSession(&configCopy, target, exporter); err != nil {
+			return nil, fmt.Errorf("%s: %s", target, err)
+		}
+	}
+
+	return h, nil
+}
+
+// newHTTPPingProtocols pings the same target with HTTP/1.1, HTTP/2 and HTTP/3 concurrently
+func newHTTPPingProtocols(config *Config, consoleLogger ConsoleLogger, exporter *prometheusExporter) (HTTPPing, error) {
+	h := &httpPingMulti{
+		config:        config,
+		consoleLogger: consoleLogger,
+		title:         "protocols",
+		header:        "protocol",
+		phases:        []stats.TimerType{stats.DNS, stats.TCP, stats.TLS, stats.QUIC},
+	}
+
+	protocols := []struct {
+		label string
+		prep  func(*Config)
+	}{
+		{"HTTP/1.1", func(c *Config) { c.HTTP1 = true }},
+		{"HTTP/2", func(c *Config) { c.HTTP2 = true }},
+		{"HTTP/3", func(c *Config) { c.HTTP3 = true }},
+	}
+
+	for _, protocol := range protocols {
+		configCopy := *config
+		configCopy.HTTP1, configCopy.HTTP2, configCopy.HTTP3 = false, false, false
+		protocol.prep(&configCopy)
+
+		if err := h.addSession(&configCopy, protocol.label, exporter); err != nil {
+			return nil, fmt.Errorf("%s: %s", protocol.label, err)
+		}
+	}
+
+	return h, nil
+}
+
+func (h *httpPingMulti) addSession(config *Config, label string, exporter *prometheusExporter) error {
+	sessionConsoleLogger := h.consoleLogger
+	if config.OutputFormat != OutputJSONL {
+		sessionConsoleLogger = newConsolePrefixLogger(h.consoleLogger, fmt.Sprintf("[%s] ", label))
+	}
+
+	pinger, logger, err := newPingSession(config, sessionConsoleLogger, exporter)
+	if err != nil {
+		return err
+	}
+
+	h.sessions = append(h.sessions, &pingSession{
+		label:              label,
+		pinger:             pinger,
+		logger:             logger,
+		throughputMeasurer: newThroughputMeasurer(),
+	})
+	return nil
+}
+
+func (h *httpPingMulti) ping(ctx context.Context) <-chan sessionMeasure {
+	measures := make(chan sessionMeasure)
+
+	var wg sync.WaitGroup
+
+	for _, session := range h.sessions {
+		wg.Add(1)
+		go func(session *pingSession) {
+			defer wg.Done()
+			for measure := range session.pinger.Ping(ctx) {
+				measures <- sessionMeasure{session: session, measure: measure}
+			}
+		}(session)
+	}
+
+	go func() {
+		wg.Wait()
+		close(measures)
+	}()
+
+	return measures
+}
+
+// Run pings all the targets concurrently, returns an error if something goes wrong, nil otherwise
+func (h *httpPingMulti) Run() error {
+
+	ctx, cancel := runContext(h.config)
+	defer cancel()
+
+	for _, session := range h.sessions {
+		_, _ = session.logger.Printf("HTTP-PING %s %s\n", session.pinger.URL(), h.config.Method)
+	}
+	if h.config.OutputFormat != OutputJSONL {
+		_, _ = h.consoleLogger.Printf("\n")
+	}
+
+	measuresChannel := h.ping(ctx)
+
+	tickerChan := make(<-chan time.Time)
+	tpuStarted := false
+
+	loop := true
+
+	for loop {
+		select {
+		case <-tickerChan:
+			for _, session := range h.sessions {
+				session.logger.onTick(session.throughputMeasurer.Measure())
+			}
+
+		case sm, ok := <-measuresChannel:
+			if !ok {
+				loop = false
+				break
+			}
+			session, measure := sm.session, sm.measure
+
+			normalizePhases(measure)
+			session.logger.onMeasure(measure)
+			reportTLSInfo(h.config, session.logger, measure)
+			if h.config.Throughput && !tpuStarted {
+				for _, s := range h.sessions {
+					s.throughputMeasurer.Measure()
+				}
+				tickerChan = (time.NewTicker(h.config.ThroughputRefresh)).C
+				tpuStarted = true
+			}
+			if !measure.IsFailure {
+				session.throughputMeasurer.Count(measure.MeasuresCollection.Get(stats.Total))
+
+				session.logger.bell()
+			}
+		}
+	}
+
+	for _, session := range h.sessions {
+		session.logger.onClose()
+		if h.config.Throughput {
+			session.logger.onThroughputClose()
+		}
+	}
+
+	if h.config.OutputFormat != OutputJSONL {
+		h.printComparison()
+	}
+
+	var breaches []string
+	for _, session := range h.sessions {
+		for _, breach := range evaluateThresholds(h.config, session.logger) {
+			breaches = append(breaches, fmt.Sprintf("%s: %s", session.label, breach))
+		}
+	}
+	if len(breaches) > 0 {
+		return &ThresholdError{Breaches: breaches}
+	}
+	return nil
+}
+
+func (h *httpPingMulti) printComparison() {
+	rows := make([]comparisonRow, len(h.sessions))
+	for i, session := range h.sessions {
+		rows[i] = comparisonRow{label: session.label, measures: session.logger.getMeasures()}
+	}
+
+	_, _ = h.consoleLogger.Printf("\n")
+	printComparison(h.consoleLogger, h.title, h.header, h.phases, rows)
+
+	if h.flagOutliers {
+		printOutliers(h.consoleLogger, rows)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHTTPPingMulti(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, Targets: []string{"https://a.example", "https://b.example"}}, &consoleLoggerMock{b: b})
+	for _, session := range instance.(*httpPingMulti).sessions {
+		session.pinger = &PingerMock{}
+	}
+	_ = instance.Run()
+
+	out, _ := io.ReadAll(b)
+
+	for _, want := range []string{
+		"[https://a.example] 10 requests sent, 10 answers received, 0.0% loss",
+		"[https://b.example] 10 requests sent, 10 answers received, 0.0% loss",
+		"--- comparison of targets (ms) ---",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("output should contain %q", want)
+		}
+	}
+}
+
+func TestHTTPPingMultiJSONL(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 2, OutputFormat: OutputJSONL, Targets: []string{"https://a.example", "https://b.example"}}, &consoleLoggerMock{b: b})
+	for _, session := range instance.(*httpPingMulti).sessions {
+		session.pinger = &PingerMock{}
+	}
+	_ = instance.Run()
+
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Fatalf("every line should be a JSON object, not %q", line)
+		}
+	}
+}
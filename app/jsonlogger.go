@@ -0,0 +1,412 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/json"
+	"fever.ch/http-ping/net/sockettrace"
+	"fever.ch/http-ping/stats"
+	"math"
+	"time"
+)
+
+// jsonLogger outputs one JSON object per line (JSON Lines), one for each measure and a final one for the statistics
+type jsonLogger struct {
+	quietLogger
+}
+
+type jsonMeasure struct {
+	Type         string             `json:"type"`
+	Target       string             `json:"target"`
+	Seq          int64              `json:"seq"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Proto        string             `json:"proto,omitempty"`
+	StatusCode   int                `json:"status_code,omitempty"`
+	RemoteAddr   string             `json:"remote_addr,omitempty"`
+	DNSServer    string             `json:"dns_server,omitempty"`
+	Bytes        int64              `json:"bytes"`
+	InBytes      int64              `json:"in_bytes"`
+	OutBytes     int64              `json:"out_bytes"`
+	SocketReused bool               `json:"socket_reused"`
+	Compressed   bool               `json:"compressed"`
+	TLSVersion   string             `json:"tls_version,omitempty"`
+	TLSResumed   bool               `json:"tls_resumed"`
+	Used0RTT     bool               `json:"used_0rtt"`
+	TCPInfo      *jsonTCPInfo       `json:"tcp_info,omitempty"`
+	Success      bool               `json:"success"`
+	Cancelled    bool               `json:"cancelled,omitempty"`
+	FailureCause string             `json:"failure_cause,omitempty"`
+	Phases       map[string]float64 `json:"phases_ms"`
+}
+
+type jsonTCPInfo struct {
+	RTT              float64 `json:"rtt_ms"`
+	RTTVar           float64 `json:"rttvar_ms"`
+	Retransmits      uint32  `json:"retransmits"`
+	CongestionWindow uint32  `json:"cwnd"`
+	MSS              uint32  `json:"mss"`
+}
+
+func newJSONTCPInfo(info *sockettrace.TCPInfo) *jsonTCPInfo {
+	if info == nil {
+		return nil
+	}
+	return &jsonTCPInfo{
+		RTT:              float64(info.RTT) / float64(time.Millisecond),
+		RTTVar:           float64(info.RTTVar) / float64(time.Millisecond),
+		Retransmits:      info.Retransmits,
+		CongestionWindow: info.CongestionWindow,
+		MSS:              info.MSS,
+	}
+}
+
+type jsonStats struct {
+	Min    float64 `json:"min"`
+	Avg    float64 `json:"avg"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+}
+
+type jsonLatencyStats struct {
+	jsonStats
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99.9"`
+}
+
+type jsonHistogramBin struct {
+	From  float64 `json:"from_ms"`
+	To    float64 `json:"to_ms"`
+	Count uint64  `json:"count"`
+}
+
+type jsonSummary struct {
+	Type            string                     `json:"type"`
+	URL             string                     `json:"url"`
+	Address         string                     `json:"address,omitempty"`
+	Protocol        string                     `json:"protocol,omitempty"`
+	RequestsSent    int64                      `json:"requests_sent"`
+	AnswersReceived int64                      `json:"answers_received"`
+	Cancelled       int64                      `json:"cancelled"`
+	Loss            float64                    `json:"loss"`
+	RoundTrip       *jsonLatencyStats          `json:"round_trip_ms,omitempty"`
+	Histogram       []jsonHistogramBin         `json:"histogram,omitempty"`
+	Phases          map[string]*jsonPhaseStats `json:"phases_ms,omitempty"`
+	Handshakes      *jsonHandshakes            `json:"handshakes,omitempty"`
+	DNS             *jsonDNS                   `json:"dns,omitempty"`
+}
+
+type jsonDNS struct {
+	CacheHits   *int64          `json:"cache_hits,omitempty"`
+	CacheMisses *int64          `json:"cache_misses,omitempty"`
+	Changes     []jsonDNSChange `json:"changes,omitempty"`
+}
+
+type jsonDNSChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Host      string    `json:"host"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+}
+
+type jsonHandshakes struct {
+	Full    *jsonPhaseStats `json:"full_ms,omitempty"`
+	Resumed *jsonPhaseStats `json:"resumed_ms,omitempty"`
+	ZeroRTT int64           `json:"zero_rtt"`
+}
+
+type jsonPhaseStats struct {
+	Count uint64 `json:"count"`
+	jsonLatencyStats
+}
+
+type jsonThroughput struct {
+	Type          string    `json:"type"`
+	Timestamp     time.Time `json:"timestamp"`
+	QueriesPerSec float64   `json:"queries_per_sec"`
+	AvgLatency    *float64  `json:"avg_latency_ms,omitempty"`
+}
+
+type jsonThroughputSummary struct {
+	Type          string     `json:"type"`
+	QueriesPerSec *jsonStats `json:"queries_per_sec,omitempty"`
+}
+
+type jsonThresholdCheck struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Limit  string `json:"limit"`
+	Passed bool   `json:"passed"`
+}
+
+type jsonThresholds struct {
+	Type   string               `json:"type"`
+	URL    string               `json:"url"`
+	Passed bool                 `json:"passed"`
+	Checks []jsonThresholdCheck `json:"checks"`
+}
+
+type jsonCertificate struct {
+	Subject  string    `json:"subject"`
+	SANs     []string  `json:"sans,omitempty"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+	Expiring bool      `json:"expiring"`
+}
+
+type jsonTLSInfo struct {
+	Type         string            `json:"type"`
+	URL          string            `json:"url"`
+	Version      string            `json:"version"`
+	CipherSuite  string            `json:"cipher_suite"`
+	ALPN         string            `json:"alpn,omitempty"`
+	Group        string            `json:"group,omitempty"`
+	Resumed      bool              `json:"resumed"`
+	OCSPStapled  bool              `json:"ocsp_stapled"`
+	OCSPStatus   string            `json:"ocsp_status,omitempty"`
+	Certificates []jsonCertificate `json:"certificates"`
+}
+
+// newJSONStats returns nil when some values are not finite, as they cannot be represented in JSON
+func newJSONStats(min, avg, max, stdDev float64) *jsonStats {
+	for _, v := range []float64{min, avg, max, stdDev} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	}
+	return &jsonStats{Min: min, Avg: avg, Max: max, StdDev: stdDev}
+}
+
+func newJSONLatencyStats(ps *stats.PingStats) *jsonLatencyStats {
+	ms := func(d stats.Measure) float64 {
+		return d.ToFloat(time.Millisecond)
+	}
+
+	s := newJSONStats(ms(ps.Min), ms(ps.Average), ms(ps.Max), ms(ps.StdDev))
+	if s == nil {
+		return nil
+	}
+	return &jsonLatencyStats{jsonStats: *s, P50: ms(ps.P50), P90: ms(ps.P90), P95: ms(ps.P95), P99: ms(ps.P99), P999: ms(ps.P999)}
+}
+
+// newJSONPhaseStats returns nil when the histogram is empty
+func newJSONPhaseStats(h *stats.Histogram) *jsonPhaseStats {
+	if h.Count() == 0 {
+		return nil
+	}
+	ls := newJSONLatencyStats(stats.PingStatsFromHistogram(h))
+	if ls == nil {
+		return nil
+	}
+	return &jsonPhaseStats{Count: h.Count(), jsonLatencyStats: *ls}
+}
+
+func newJSONLogger(config *Config, consoleLogger ConsoleLogger, pinger Pinger) PingLogger {
+	return &jsonLogger{makeQuietLogger(config, consoleLogger, pinger)}
+}
+
+// Printf discards the human-readable decorations, only JSON objects are written on the output
+func (logger *jsonLogger) Printf(_ string, _ ...any) (int, error) {
+	return 0, nil
+}
+
+func (logger *jsonLogger) emit(v any) {
+	if b, err := json.Marshal(v); err == nil {
+		_, _ = logger.consoleLogger.Printf("%s\n", b)
+	}
+}
+
+func (logger *jsonLogger) bell() {
+	// no bell in machine-readable output
+}
+
+func (logger *jsonLogger) onMeasure(measure *HTTPMeasure) {
+	logger.quietLogger.onMeasure(measure)
+
+	phases := make(map[string]float64)
+	if measure.MeasuresCollection != nil {
+		for _, tt := range stats.TimerTypes {
+			if m := measure.MeasuresCollection.Get(tt); m.IsValid() {
+				phases[tt.String()] = m.ToFloat(time.Millisecond)
+			}
+		}
+	}
+
+	logger.emit(&jsonMeasure{
+		Type:         "measure",
+		Target:       logger.pinger.URL(),
+		Seq:          logger.measures.attempts + logger.measures.cancelled,
+		Timestamp:    time.Now(),
+		Proto:        measure.Proto,
+		StatusCode:   measure.StatusCode,
+		RemoteAddr:   measure.RemoteAddr,
+		DNSServer:    measure.DNSServer,
+		Bytes:        measure.Bytes,
+		InBytes:      measure.InBytes,
+		OutBytes:     measure.OutBytes,
+		SocketReused: measure.SocketReused,
+		Compressed:   measure.Compressed,
+		TLSVersion:   measure.TLSVersion,
+		TLSResumed:   measure.TLSResumed,
+		Used0RTT:     measure.Used0RTT,
+		TCPInfo:      newJSONTCPInfo(measure.TCPInfo),
+		Success:      !measure.IsFailure,
+		Cancelled:    measure.Cancelled,
+		FailureCause: measure.FailureCause,
+		Phases:       phases,
+	})
+}
+
+func (logger *jsonLogger) onTick(m throughputMeasure) {
+	logger.quietLogger.onTick(m)
+
+	tp := &jsonThroughput{
+		Type:          "throughput",
+		Timestamp:     time.Now(),
+		QueriesPerSec: float64(m.count) / m.dt.Seconds(),
+	}
+	if m.count > 0 {
+		avg := m.queriesDuration.ToFloat(time.Millisecond) / float64(m.count)
+		tp.AvgLatency = &avg
+	}
+	logger.emit(tp)
+}
+
+func (logger *jsonLogger) onClose() {
+	summary := &jsonSummary{
+		Type:            "summary",
+		URL:             logger.pinger.URL(),
+		Address:         addressLabel(logger.config),
+		RequestsSent:    logger.measures.attempts,
+		AnswersReceived: logger.measures.successes,
+		Cancelled:       logger.measures.cancelled,
+		Loss:            logger.measures.lossRate(),
+	}
+
+	// the protocol is only reported when it was forced
+	if protocol := protocolLabel(logger.config); protocol != "auto" {
+		summary.Protocol = protocol
+	}
+
+	if logger.measures.successes > 0 {
+		summary.RoundTrip = newJSONLatencyStats(stats.PingStatsFromHistogram(logger.measures.latencies))
+
+		summary.Phases = make(map[string]*jsonPhaseStats)
+		for _, tt := range stats.TimerTypes {
+			if h := logger.measures.phases.Get(tt); h != nil {
+				if ps := newJSONPhaseStats(h); ps != nil {
+					summary.Phases[tt.String()] = ps
+				}
+			}
+		}
+
+		if logger.config.TLSResumption {
+			summary.Handshakes = &jsonHandshakes{
+				Full:    newJSONPhaseStats(logger.measures.fullHandshakes),
+				Resumed: newJSONPhaseStats(logger.measures.resumedHandshakes),
+				ZeroRTT: logger.measures.zeroRTT,
+			}
+		}
+
+		if logger.config.Histogram {
+			for _, bin := range logger.measures.latencies.Bins(histogramRows) {
+				summary.Histogram = append(summary.Histogram, jsonHistogramBin{
+					From:  bin.From.ToFloat(time.Millisecond),
+					To:    bin.To.ToFloat(time.Millisecond),
+					Count: bin.Count,
+				})
+			}
+		}
+	}
+
+	summary.DNS = logger.newJSONDNS()
+
+	logger.emit(summary)
+}
+
+// newJSONDNS describes the hits and misses of the DNS cache, when enabled, and the changes of the answers
+func (logger *jsonLogger) newJSONDNS() *jsonDNS {
+	if logger.measures.dnsCache == nil {
+		return nil
+	}
+
+	hits, misses, changes := logger.measures.dnsCache.stats()
+	if !logger.config.CacheDNSRequests && len(changes) == 0 {
+		return nil
+	}
+
+	dns := &jsonDNS{}
+	if logger.config.CacheDNSRequests {
+		dns.CacheHits, dns.CacheMisses = &hits, &misses
+	}
+	for _, change := range changes {
+		dns.Changes = append(dns.Changes, jsonDNSChange{Timestamp: change.Time, Host: change.Host, From: change.From, To: change.To})
+	}
+	return dns
+}
+
+func (logger *jsonLogger) onThroughputClose() {
+	summary := &jsonThroughputSummary{Type: "throughput_summary"}
+
+	if len(logger.measures.throughputs) > 0 {
+		stat := stats.ComputeStats(throughputMeasuresIterable(logger.measures.throughputs))
+		summary.QueriesPerSec = newJSONStats(stat.Min, stat.Average, stat.Max, stat.StdDev)
+	}
+
+	logger.emit(summary)
+}
+
+func (logger *jsonLogger) onThresholds(checks []thresholdCheck) {
+	thresholds := &jsonThresholds{Type: "thresholds", URL: logger.pinger.URL(), Passed: true}
+
+	for _, check := range checks {
+		thresholds.Checks = append(thresholds.Checks, jsonThresholdCheck{Name: check.name, Value: check.value, Limit: check.limit, Passed: check.passed})
+		thresholds.Passed = thresholds.Passed && check.passed
+	}
+
+	logger.emit(thresholds)
+}
+
+func (logger *jsonLogger) onTLSInfo(info *tlsInfo) {
+	record := &jsonTLSInfo{
+		Type:        "tls_info",
+		URL:         logger.pinger.URL(),
+		Version:     info.version,
+		CipherSuite: info.cipherSuite,
+		ALPN:        info.alpn,
+		Group:       info.group,
+		Resumed:     info.resumed,
+		OCSPStapled: info.ocspStapled,
+		OCSPStatus:  info.ocspStatus,
+	}
+
+	for _, cert := range info.certificates {
+		record.Certificates = append(record.Certificates, jsonCertificate{
+			Subject:  cert.subject,
+			SANs:     cert.sans,
+			Issuer:   cert.issuer,
+			NotAfter: cert.notAfter,
+			DaysLeft: cert.daysLeft,
+			Expiring: cert.expiring,
+		})
+	}
+
+	logger.emit(record)
+}
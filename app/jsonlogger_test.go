@@ -0,0 +1,54 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, OutputFormat: OutputJSONL, HTTP2: true}, &consoleLoggerMock{b: b})
+	instance.(*httpPingImpl).pinger = &PingerMock{}
+	_ = instance.Run()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(b)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("output line is not valid JSON: %q", scanner.Text())
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 11 {
+		t.Fatalf("expected 10 measures and a summary, got %d lines", len(lines))
+	}
+
+	if lines[0]["type"] != "measure" || lines[0]["seq"] != float64(1) {
+		t.Errorf("first line should be the first measure, got %v", lines[0])
+	}
+
+	summary := lines[10]
+	if summary["type"] != "summary" || summary["requests_sent"] != float64(10) || summary["answers_received"] != float64(10) || summary["protocol"] != "h2" {
+		t.Errorf("last line should be the summary, got %v", summary)
+	}
+}
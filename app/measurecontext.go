@@ -21,14 +21,34 @@ import (
 	"crypto/tls"
 	"fever.ch/http-ping/net/sockettrace"
 	"fever.ch/http-ping/stats"
+	"github.com/quic-go/quic-go"
+	"net"
 	"net/http/httptrace"
+	"net/url"
 )
 
 type measureContext struct {
 	timerRegistry *stats.TimerRegistry
 	webClientImpl *webClientImpl
 	remoteAddr    string
+	dnsServer     string
 	reused        bool
+	conn          net.Conn
+	tcpInfo       *sockettrace.TCPInfo
+	tlsState      *tls.ConnectionState
+	quicConn      quic.EarlyConnection
+
+	// proxy used by the request, if any, and whether a tunnel to the target is established through it
+	proxy             *url.URL
+	tunnel, tunneling bool
+}
+
+type measureContextKey struct{}
+
+// measureContextFrom returns the measureContext of the request done with ctx, if any
+func measureContextFrom(ctx context.Context) *measureContext {
+	measureContext, _ := ctx.Value(measureContextKey{}).(*measureContext)
+	return measureContext
 }
 
 func newMeasureContext(impl *webClientImpl) *measureContext {
@@ -46,11 +66,15 @@ func (measureContext *measureContext) getClientTrace() *httptrace.ClientTrace {
 
 	return &httptrace.ClientTrace{
 		TLSHandshakeStart: func() {
+			measureContext.endTunnel()
 			measureContext.timerRegistry.Get(stats.TLS).Start()
 		},
 
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 			measureContext.timerRegistry.Get(stats.TLS).Stop()
+			if err == nil {
+				measureContext.tlsState = &state
+			}
 		},
 		DNSStart: func(info httptrace.DNSStartInfo) {
 			measureContext.timerRegistry.Get(stats.DNS).Start()
@@ -65,7 +89,9 @@ func (measureContext *measureContext) getClientTrace() *httptrace.ClientTrace {
 		},
 
 		GotConn: func(info httptrace.GotConnInfo) {
+			measureContext.endTunnel()
 			measureContext.remoteAddr = info.Conn.RemoteAddr().String()
+			measureContext.conn = info.Conn
 			measureContext.timerRegistry.Get(stats.Conn).Stop()
 			measureContext.timerRegistry.Get(stats.Req).Start()
 			measureContext.timerRegistry.Get(stats.ReqAndWait).StartForce()
@@ -78,6 +104,7 @@ func (measureContext *measureContext) getClientTrace() *httptrace.ClientTrace {
 		},
 
 		GotFirstResponseByte: func() {
+			measureContext.updateTCPInfo()
 			measureContext.timerRegistry.Get(stats.Wait).Stop()
 			measureContext.timerRegistry.Get(stats.ReqAndWait).Stop()
 
@@ -96,18 +123,50 @@ func (measureContext *measureContext) getConnTrace() *sockettrace.ConnTrace {
 			measureContext.webClientImpl.writes += int64(i)
 		},
 		TCPStart: func() {
-			measureContext.timerRegistry.Get(stats.TCP).Start()
+			if measureContext.proxy != nil {
+				measureContext.timerRegistry.Get(stats.ProxyConnect).Start()
+			} else {
+				measureContext.timerRegistry.Get(stats.TCP).Start()
+			}
 		},
 		TCPEstablished: func() {
-			measureContext.timerRegistry.Get(stats.TCP).Stop()
+			if measureContext.proxy == nil {
+				measureContext.timerRegistry.Get(stats.TCP).Stop()
+				return
+			}
+			measureContext.timerRegistry.Get(stats.ProxyConnect).Stop()
+			if measureContext.tunnel {
+				measureContext.timerRegistry.Get(stats.Tunnel).Start()
+				measureContext.tunneling = true
+			}
 		},
 	}
 }
 
-func (measureContext *measureContext) ctx() context.Context {
+// updateTCPInfo samples the kernel statistics of the connection, as long as it is open (it might be closed by the
+// server once the response is sent)
+func (measureContext *measureContext) updateTCPInfo() {
+	if measureContext.conn == nil {
+		return
+	}
+	if info := sockettrace.GetTCPInfo(measureContext.conn); info != nil {
+		measureContext.tcpInfo = info
+	}
+}
+
+// endTunnel ends the tunnel phase, if one is ongoing: SOCKS5 tunnels are only known to be established once the TLS
+// handshake starts or the connection is handed over
+func (measureContext *measureContext) endTunnel() {
+	if measureContext.tunneling {
+		measureContext.timerRegistry.Get(stats.Tunnel).Stop()
+		measureContext.tunneling = false
+	}
+}
+
+func (measureContext *measureContext) ctx(parent context.Context) context.Context {
 	return httptrace.WithClientTrace(
 		sockettrace.WithTrace(
-			context.Background(),
+			context.WithValue(parent, measureContextKey{}, measureContext),
 			measureContext.getConnTrace()),
 		measureContext.getClientTrace())
 }
@@ -17,6 +17,9 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
+	"fever.ch/http-ping/net/sockettrace"
 	"fever.ch/http-ping/stats"
 	"fmt"
 	"net/http"
@@ -35,20 +38,26 @@ type HTTPMeasure struct {
 	SocketReused bool
 	Compressed   bool
 	RemoteAddr   string
+	DNSServer    string // server that resolved the name of the target, if it had to be resolved
 	TLSEnabled   bool
 	TLSVersion   string
+	TLSState     *tls.ConnectionState
+	TLSResumed   bool
+	Used0RTT     bool
 	AltSvcH3     *string
+	TCPInfo      *sockettrace.TCPInfo // kernel statistics of the connection, after the request (Linux only)
 
 	MeasuresCollection *stats.MeasuresCollection
 
 	IsFailure    bool
+	Cancelled    bool
 	FailureCause string
 	Headers      *http.Header
 }
 
 // Pinger does the calls to the actual HTTP/S component
 type Pinger interface {
-	Ping() <-chan *HTTPMeasure
+	Ping(ctx context.Context) <-chan *HTTPMeasure
 
 	URL() string
 }
@@ -79,18 +88,35 @@ func (pinger *pingerImpl) URL() string {
 	return pinger.clientBuilder.URL()
 }
 
-// Ping actually does the pinging specified in config
-func (pinger *pingerImpl) Ping() <-chan *HTTPMeasure {
-	measures := make(chan *HTTPMeasure)
+// sleep waits for a given duration, it returns false if ctx is done before
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	var wg sync.WaitGroup
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
+// Ping actually does the pinging specified in config, until all the requests are done or ctx is done
+func (pinger *pingerImpl) Ping(ctx context.Context) <-chan *HTTPMeasure {
 	if pinger.config.FollowRedirects {
 		i := pinger.clientBuilder.NewInstance()
-		i.DoMeasure(true)
+		i.DoMeasure(ctx, true)
 		pinger.clientBuilder.SetURL(i.GetURL())
 	}
 
+	if pinger.config.Rate > 0 {
+		return pinger.pingAtRate(ctx)
+	}
+
+	measures := make(chan *HTTPMeasure)
+
+	var wg sync.WaitGroup
+
 	for i := 0; i < pinger.config.Workers; i++ {
 		wg.Add(1)
 
@@ -101,15 +127,17 @@ func (pinger *pingerImpl) Ping() <-chan *HTTPMeasure {
 			defer wg.Done()
 
 			if !pinger.config.DisableKeepAlive {
-				client.DoMeasure(pinger.config.FollowRedirects)
-				time.Sleep(time.Second)
+				client.DoMeasure(ctx, pinger.config.FollowRedirects)
+				if !sleep(ctx, time.Second) {
+					return
+				}
 			}
 
-			for a := int64(0); a < pinger.config.Count; a++ {
-				measures <- client.DoMeasure(false)
+			for a := int64(0); a < pinger.config.Count && ctx.Err() == nil; a++ {
+				measures <- client.DoMeasure(ctx, false)
 
-				if a < pinger.config.Count-1 {
-					time.Sleep(pinger.config.Interval)
+				if a < pinger.config.Count-1 && !sleep(ctx, pinger.config.Interval) {
+					return
 				}
 			}
 		}()
@@ -121,3 +149,69 @@ func (pinger *pingerImpl) Ping() <-chan *HTTPMeasure {
 	}()
 	return measures
 }
+
+// pingAtRate sends requests at a fixed rate, regardless of the time taken by the responses (open-loop). Requests are
+// dispatched to a pool of workers growing up to config.MaxWorkers, when all of them are busy the requests are delayed,
+// but their latency is still measured from the time they should have been sent (coordinated omission correction).
+func (pinger *pingerImpl) pingAtRate(ctx context.Context) <-chan *HTTPMeasure {
+	measures := make(chan *HTTPMeasure)
+	jobs := make(chan time.Time)
+
+	var wg sync.WaitGroup
+
+	worker := func() {
+		defer wg.Done()
+
+		client := pinger.clientBuilder.NewInstance()
+
+		for intended := range jobs {
+			delay := time.Since(intended)
+			measure := client.DoMeasure(ctx, false)
+
+			if measure.MeasuresCollection != nil {
+				if total := measure.MeasuresCollection.Get(stats.Total); total.IsValid() && delay > 0 {
+					measure.MeasuresCollection.Set(stats.Total, total+stats.Measure(delay))
+				}
+			}
+			measures <- measure
+		}
+	}
+
+	go func() {
+		period := time.Duration(float64(time.Second) / pinger.config.Rate)
+		start := time.Now()
+		workers := 0
+
+	dispatch:
+		for a := int64(0); a < pinger.config.Count; a++ {
+			intended := start.Add(time.Duration(a) * period)
+			if !sleep(ctx, time.Until(intended)) {
+				break
+			}
+
+			select {
+			case jobs <- intended:
+				continue
+			default:
+			}
+
+			if workers < pinger.config.MaxWorkers {
+				workers++
+				wg.Add(1)
+				go worker()
+			}
+
+			select {
+			case jobs <- intended:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+
+		wg.Wait()
+		close(measures)
+	}()
+
+	return measures
+}
@@ -17,8 +17,12 @@
 package app
 
 import (
+	"context"
+	"fever.ch/http-ping/stats"
+	"math"
 	"net/url"
 	"testing"
+	"time"
 )
 
 type webClientMock struct{}
@@ -29,7 +33,7 @@ func TestPinger(t *testing.T) {
 	wanted := 123
 	pinger, _ := NewPinger(&Config{Workers: 1, Count: int64(wanted)}, &RuntimeConfig{}, nil)
 	pinger.(*pingerImpl).clientBuilder = &webClientBuilderMock{}
-	ch := pinger.Ping()
+	ch := pinger.Ping(context.Background())
 
 	count := 0
 	for range ch {
@@ -40,7 +44,65 @@ func TestPinger(t *testing.T) {
 	}
 }
 
-func (webClientMock *webClientMock) DoMeasure(_ bool) *HTTPMeasure {
+type slowWebClientMock struct {
+	webClientMock
+	delay time.Duration
+}
+
+type slowWebClientBuilderMock struct {
+	webClientBuilderMock
+	delay time.Duration
+}
+
+func TestPingerAtRate(t *testing.T) {
+	wanted := 20
+	delay := 20 * time.Millisecond
+	pinger, _ := NewPinger(&Config{Count: int64(wanted), Rate: 1000, MaxWorkers: 1}, &RuntimeConfig{}, nil)
+	pinger.(*pingerImpl).clientBuilder = &slowWebClientBuilderMock{delay: delay}
+
+	count := 0
+	var last stats.Measure
+	for measure := range pinger.Ping(context.Background()) {
+		count++
+		last = measure.MeasuresCollection.Get(stats.Total)
+	}
+	if count != wanted {
+		t.Fatalf("%d != %d, number of measures didn't match", count, wanted)
+	}
+
+	// with a single worker, the last request is sent (wanted-1)*delay late, which has to be accounted in its latency
+	if min := stats.Measure(time.Duration(wanted-1) * delay); last < min {
+		t.Fatalf("latency of the last request %s is lower than %s, coordinated omission is not corrected", time.Duration(last), time.Duration(min))
+	}
+}
+
+func TestPingerStopsWithContext(t *testing.T) {
+	pinger, _ := NewPinger(&Config{Workers: 4, Count: math.MaxInt64, DisableKeepAlive: true}, &RuntimeConfig{}, nil)
+	pinger.(*pingerImpl).clientBuilder = &slowWebClientBuilderMock{delay: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	for range pinger.Ping(ctx) {
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("pinging lasted %s, it should have stopped with the context", elapsed)
+	}
+}
+
+func (webClientMock *slowWebClientMock) DoMeasure(_ context.Context, _ bool) *HTTPMeasure {
+	time.Sleep(webClientMock.delay)
+	mc := stats.NewMeasureRegistry()
+	mc.Set(stats.Total, stats.Measure(webClientMock.delay))
+	return &HTTPMeasure{MeasuresCollection: mc}
+}
+
+func (webClientBuilderMock *slowWebClientBuilderMock) NewInstance() WebClient {
+	return &slowWebClientMock{delay: webClientBuilderMock.delay}
+}
+
+func (webClientMock *webClientMock) DoMeasure(_ context.Context, _ bool) *HTTPMeasure {
 	return &HTTPMeasure{}
 }
 
@@ -27,26 +27,114 @@ type PingLogger interface {
 	onTick(measure throughputMeasure)
 	onClose()
 	onThroughputClose()
+	onThresholds(checks []thresholdCheck)
+	onTLSInfo(info *tlsInfo)
 	bell()
+	getMeasures() *measures
 	Printf(format string, a ...any) (int, error)
 }
 
 type measures struct {
 	successes int64
 	attempts  int64
-	latencies []stats.Measure
+	cancelled int64
+	latencies *stats.Histogram
+	phases    *stats.PhaseHistograms
+
+	// time of the first and last answers
+	first, last time.Time
+
+	throughputs []throughputMeasure
+
+	tlsInfoReported bool
+
+	// cache of the resolutions of the session, if any
+	dnsCache *DNSCache
+
+	// durations of the TLS (or QUIC) handshakes, depending on whether a session was resumed
+	fullHandshakes, resumedHandshakes *stats.Histogram
+	zeroRTT                           int64
+}
+
+// handshake returns the duration of the TLS or QUIC handshake of a measure, invalid if no handshake was done
+func handshake(measure *HTTPMeasure) stats.Measure {
+	if m := measure.MeasuresCollection.Get(stats.QUIC); m.IsValid() {
+		return m
+	}
+	return measure.MeasuresCollection.Get(stats.TLS)
+}
+
+func handshakeKind(measure *HTTPMeasure) string {
+	switch {
+	case measure.Used0RTT:
+		return "resumed+0-RTT"
+	case measure.TLSResumed:
+		return "resumed"
+	default:
+		return "full"
+	}
+}
+
+func (m *measures) lossRate() float64 {
+	if m.attempts == 0 {
+		return 0
+	}
+	return float64(m.attempts-m.successes) / float64(m.attempts)
+}
+
+// throughput returns the average number of answers per second, as sampled during the run if it was measured
+func (m *measures) throughput() float64 {
+	if len(m.throughputs) > 0 {
+		return stats.ComputeStats(throughputMeasuresIterable(m.throughputs)).Average
+	}
+	if elapsed := m.last.Sub(m.first).Seconds(); elapsed > 0 {
+		return float64(m.successes-1) / elapsed
+	}
+	return 0
+}
+
+// normalizePhases adapts the phases of a measure to the protocol: with HTTP/3 the TLS handshake is actually the QUIC
+// handshake, while the combined request and wait phase is only relevant for HTTP/3
+func normalizePhases(measure *HTTPMeasure) {
+	if measure.MeasuresCollection == nil {
+		return
+	}
+	if strings.HasPrefix(measure.Proto, "HTTP/3") {
+		measure.MeasuresCollection.Set(stats.QUIC, measure.MeasuresCollection.Get(stats.TLS))
+		measure.MeasuresCollection.Set(stats.TLS, stats.MeasureNotValid)
+	} else {
+		measure.MeasuresCollection.Set(stats.ReqAndWait, stats.MeasureNotValid)
+	}
 }
 
 type quietLogger struct {
-	config             *Config
-	consoleLogger      ConsoleLogger
-	pinger             Pinger
-	measures           measures
-	throughputMeasures []throughputMeasure
+	config        *Config
+	consoleLogger ConsoleLogger
+	pinger        Pinger
+	measures      measures
+}
+
+func makeQuietLogger(config *Config, consoleLogger ConsoleLogger, pinger Pinger) quietLogger {
+	return quietLogger{
+		config:        config,
+		consoleLogger: consoleLogger,
+		pinger:        pinger,
+		measures: measures{
+			latencies:         stats.NewHistogram(),
+			phases:            stats.NewPhaseHistograms(),
+			fullHandshakes:    stats.NewHistogram(),
+			resumedHandshakes: stats.NewHistogram(),
+		},
+	}
 }
 
 func newQuietLogger(config *Config, consoleLogger ConsoleLogger, pinger Pinger) PingLogger {
-	return &quietLogger{config: config, consoleLogger: consoleLogger, pinger: pinger}
+	logger := makeQuietLogger(config, consoleLogger, pinger)
+	return &logger
+}
+
+func (logger *quietLogger) getMeasures() *measures {
+	return &logger.measures
 }
 
 func (logger *quietLogger) Printf(format string, a ...any) (int, error) {
@@ -54,10 +142,30 @@ func (logger *quietLogger) Printf(format string, a ...any) (int, error) {
 }
 
 func (logger *quietLogger) onMeasure(m *HTTPMeasure) {
+	// requests aborted at the end of the run are neither answered nor lost
+	if m.Cancelled {
+		logger.measures.cancelled++
+		return
+	}
+
 	logger.measures.attempts++
 	if !m.IsFailure {
+		logger.measures.last = time.Now()
+		if logger.measures.successes == 0 {
+			logger.measures.first = logger.measures.last
+		}
 		logger.measures.successes++
-		logger.measures.latencies = append(logger.measures.latencies, m.MeasuresCollection.Get(stats.Total))
+		logger.measures.latencies.Record(m.MeasuresCollection.Get(stats.Total))
+		logger.measures.phases.Record(m.MeasuresCollection)
+
+		if m.TLSResumed {
+			logger.measures.resumedHandshakes.Record(handshake(m))
+		} else {
+			logger.measures.fullHandshakes.Record(handshake(m))
+		}
+		if m.Used0RTT {
+			logger.measures.zeroRTT++
+		}
 	}
 }
 
@@ -85,29 +193,96 @@ func (m *throughputMeasuresIterator) Next() stats.Observation {
 }
 
 func (logger *quietLogger) onTick(m throughputMeasure) {
-	logger.throughputMeasures = append(logger.throughputMeasures, m)
+	logger.measures.throughputs = append(logger.measures.throughputs, m)
 }
 
 func (logger *quietLogger) onClose() {
-	var lossRate = float64(0)
-	if logger.measures.attempts > 0 {
-		lossRate = float64(logger.measures.attempts-logger.measures.successes) / float64(logger.measures.attempts)
-	}
-	pingStats := stats.PingStatsFromLatencies(logger.measures.latencies)
+	lossRate := logger.measures.lossRate()
+	pingStats := stats.PingStatsFromHistogram(logger.measures.latencies)
 
 	_, _ = logger.Printf("--- %s ping statistics ---\n", logger.pinger.URL())
 
-	_, _ = logger.Printf("%d requests sent, %d answers received, %.1f%% loss\n", logger.measures.attempts, logger.measures.successes, lossRate*100)
+	_, _ = logger.Printf("%d requests sent, %d answers received, %.1f%% loss", logger.measures.attempts, logger.measures.successes, lossRate*100)
+	if logger.measures.cancelled > 0 {
+		_, _ = logger.Printf(", %d cancelled", logger.measures.cancelled)
+	}
+	_, _ = logger.Printf("\n")
 
 	if logger.measures.successes > 0 {
 		_, _ = logger.Printf("%s\n", pingStats.String())
+		_, _ = logger.Printf("%s\n", pingStats.PercentilesString())
+
+		if logger.config.Histogram {
+			logger.drawHistogram()
+		}
+
+		if logger.config.TLSResumption {
+			logger.drawHandshakes()
+		}
+	}
+
+	logger.drawDNS()
+}
+
+// drawDNS reports the hits and misses of the DNS cache, when enabled, and the changes of the answers
+func (logger *quietLogger) drawDNS() {
+	if logger.measures.dnsCache == nil {
+		return
+	}
+
+	hits, misses, changes := logger.measures.dnsCache.stats()
+	if logger.config.CacheDNSRequests {
+		_, _ = logger.Printf("\nDNS cache: %d hits, %d misses\n", hits, misses)
+	}
+	if len(changes) > 0 {
+		_, _ = logger.Printf("\nDNS answer changes:\n")
+		for _, change := range changes {
+			_, _ = logger.Printf("  %s %s: %s → %s\n", change.Time.Format(time.DateTime), change.Host, change.From, change.To)
+		}
+	}
+}
+
+func (logger *quietLogger) drawHandshakes() {
+	m := &logger.measures
+	_, _ = logger.Printf("\nhandshakes: %d full, %d resumed, 0-RTT accepted %d times\n", m.fullHandshakes.Count(), m.resumedHandshakes.Count(), m.zeroRTT)
+
+	for _, h := range []struct {
+		label     string
+		histogram *stats.Histogram
+	}{{"full", m.fullHandshakes}, {"resumed", m.resumedHandshakes}} {
+		if h.histogram.Count() > 0 {
+			ps := stats.PingStatsFromHistogram(h.histogram)
+			_, _ = logger.Printf("%s handshake min/avg/max/p99 = %.3f/%.3f/%.3f/%.3f ms\n", h.label, ps.Min.ToFloat(time.Millisecond), ps.Average.ToFloat(time.Millisecond), ps.Max.ToFloat(time.Millisecond), ps.P99.ToFloat(time.Millisecond))
+		}
+	}
+}
+
+const (
+	histogramRows  = 10
+	histogramWidth = 40
+)
+
+func (logger *quietLogger) drawHistogram() {
+	bins := logger.measures.latencies.Bins(histogramRows)
+
+	var highest uint64
+	for _, bin := range bins {
+		if bin.Count > highest {
+			highest = bin.Count
+		}
+	}
+
+	_, _ = logger.Printf("\nlatency histogram:\n")
+	for _, bin := range bins {
+		bar := strings.Repeat("#", int(bin.Count*histogramWidth/highest))
+		_, _ = logger.Printf("%10.3f - %10.3f ms | %-*s %d\n", bin.From.ToFloat(time.Millisecond), bin.To.ToFloat(time.Millisecond), histogramWidth, bar, bin.Count)
 	}
 }
 
 func (logger *quietLogger) onThroughputClose() {
 	_, _ = logger.Printf("\n")
-	if len(logger.throughputMeasures) > 0 {
-		stat := stats.ComputeStats(throughputMeasuresIterable(logger.throughputMeasures))
+	if len(logger.measures.throughputs) > 0 {
+		stat := stats.ComputeStats(throughputMeasuresIterable(logger.measures.throughputs))
 		_, _ = logger.Printf("throughput measures:\n")
 		_, _ = logger.Printf("queries throughput min/avg/max/stdev = %.1f/%.1f/%.1f/%.1f queries/sec \n", stat.Min, stat.Average, stat.Max, stat.StdDev)
 	} else {
@@ -115,25 +290,40 @@ func (logger *quietLogger) onThroughputClose() {
 	}
 }
 
+func (logger *quietLogger) onThresholds(checks []thresholdCheck) {
+	_, _ = logger.Printf("\n--- thresholds ---\n")
+	for _, check := range checks {
+		status := "passed"
+		if !check.passed {
+			status = "FAILED"
+		}
+		_, _ = logger.Printf("%s: %s\n", status, check)
+	}
+}
+
 type standardLogger struct {
 	quietLogger
 }
 
 func newStandardLogger(config *Config, consoleLogger ConsoleLogger, pinger Pinger) *standardLogger {
-	return &standardLogger{quietLogger{config: config, consoleLogger: consoleLogger, pinger: pinger}}
+	return &standardLogger{makeQuietLogger(config, consoleLogger, pinger)}
 }
 
 func (logger *standardLogger) onMeasure(measure *HTTPMeasure) {
 	logger.quietLogger.onMeasure(measure)
 
-	if logger.config.Throughput {
+	if logger.config.Throughput || measure.Cancelled {
 		return
 	}
 	if measure.IsFailure {
 		_, _ = logger.Printf("%4d: Error: %s\n", logger.measures.attempts, measure.FailureCause)
 		return
 	}
-	_, _ = logger.Printf("%8d: %s, %s, code=%d, size=%d bytes, time=%.1f ms\n", logger.measures.attempts, measure.Proto, measure.RemoteAddr, measure.StatusCode, measure.Bytes, measure.MeasuresCollection.Get(stats.Total).ToFloat(time.Millisecond))
+	_, _ = logger.Printf("%8d: %s, %s, code=%d, size=%d bytes, time=%.1f ms", logger.measures.attempts, measure.Proto, measure.RemoteAddr, measure.StatusCode, measure.Bytes, measure.MeasuresCollection.Get(stats.Total).ToFloat(time.Millisecond))
+	if logger.config.TLSResumption && handshake(measure).IsValid() {
+		_, _ = logger.Printf(", handshake=%s", handshakeKind(measure))
+	}
+	_, _ = logger.Printf("\n")
 }
 
 func (logger *standardLogger) onTick(m throughputMeasure) {
@@ -168,12 +358,6 @@ func newVerboseLogger(config *Config, consoleLogger ConsoleLogger, pinger Pinger
 }
 
 func (logger *verboseLogger) onMeasure(measure *HTTPMeasure) {
-	if strings.HasPrefix(measure.Proto, "HTTP/3") {
-		measure.MeasuresCollection.Set(stats.QUIC, measure.MeasuresCollection.Get(stats.TLS))
-		measure.MeasuresCollection.Set(stats.TLS, stats.MeasureNotValid)
-	} else {
-		measure.MeasuresCollection.Set(stats.ReqAndWait, stats.MeasureNotValid)
-	}
 	logger.standardLogger.onMeasure(measure)
 	if logger.config.Throughput {
 		return
@@ -184,8 +368,14 @@ func (logger *verboseLogger) onMeasure(measure *HTTPMeasure) {
 
 	_, _ = logger.Printf("          proto=%s, socket reused=%t, compressed=%t\n", measure.Proto, measure.SocketReused, measure.Compressed)
 	_, _ = logger.Printf("          network i/o: bytes read=%d, bytes written=%d\n", measure.InBytes, measure.OutBytes)
+	if measure.DNSServer != "" {
+		_, _ = logger.Printf("          dns server=%s\n", measure.DNSServer)
+	}
 
-	_, _ = logger.Printf("          tls version=%s\n", measure.TLSVersion)
+	_, _ = logger.Printf("          tls version=%s, session resumed=%t, 0-RTT=%t\n", measure.TLSVersion, measure.TLSResumed, measure.Used0RTT)
+	if info := measure.TCPInfo; info != nil {
+		_, _ = logger.Printf("          tcp info: rtt=%.3f ms, rttvar=%.3f ms, retransmits=%d, cwnd=%d, mss=%d\n", float64(info.RTT)/float64(time.Millisecond), float64(info.RTTVar)/float64(time.Millisecond), info.Retransmits, info.CongestionWindow, info.MSS)
+	}
 	logger.measureSum.MeasuresCollection.Append(measure.MeasuresCollection)
 
 	_, _ = logger.Printf("\n\n")
@@ -208,6 +398,45 @@ func (logger *verboseLogger) onClose() {
 		_, _ = logger.Printf("\naverage latency contributions:\n")
 
 		logger.drawMeasure(logger.measureSum)
+
+		logger.drawPhaseStats()
+	}
+}
+
+// phases lists the phases of a request as they are reported in the per-phase statistics
+var phases = []struct {
+	timerType stats.TimerType
+	label     string
+}{
+	{stats.DNS, "DNS resolution"},
+	{stats.ProxyConnect, "proxy connection"},
+	{stats.Tunnel, "proxy tunnel"},
+	{stats.TCP, "TCP handshake"},
+	{stats.QUIC, "QUIC handshake"},
+	{stats.TLS, "TLS handshake"},
+	{stats.Conn, "connection setup"},
+	{stats.Req, "request sending"},
+	{stats.Wait, "wait"},
+	{stats.ReqAndWait, "request and wait"},
+	{stats.Resp, "response ingestion"},
+	{stats.Total, "request and response"},
+}
+
+func (logger *verboseLogger) drawPhaseStats() {
+	_, _ = logger.Printf("\nper-phase statistics (ms):\n")
+	_, _ = logger.Printf("          %-22s %8s %9s %9s %9s %9s %9s %9s %9s\n", "phase", "count", "min", "avg", "max", "stddev", "p50", "p90", "p99")
+
+	ms := func(d stats.Measure) float64 {
+		return d.ToFloat(time.Millisecond)
+	}
+
+	for _, phase := range phases {
+		h := logger.measures.phases.Get(phase.timerType)
+		if h == nil {
+			continue
+		}
+		ps := stats.PingStatsFromHistogram(h)
+		_, _ = logger.Printf("          %-22s %8d %9.3f %9.3f %9.3f %9.3f %9.3f %9.3f %9.3f\n", phase.label, h.Count(), ms(ps.Min), ms(ps.Average), ms(ps.Max), ms(ps.StdDev), ms(ps.P50), ms(ps.P90), ms(ps.P99))
 	}
 }
 
@@ -219,6 +448,8 @@ func (logger *verboseLogger) drawMeasure(measure *HTTPMeasure) {
 			{label: "connection setup", duration: measure.MeasuresCollection.Get(stats.Conn),
 				children: []*measureEntry{
 					{label: "DNS resolution", duration: measure.MeasuresCollection.Get(stats.DNS)},
+					{label: "proxy connection", duration: measure.MeasuresCollection.Get(stats.ProxyConnect)},
+					{label: "proxy tunnel", duration: measure.MeasuresCollection.Get(stats.Tunnel)},
 					{label: "TCP handshake", duration: measure.MeasuresCollection.Get(stats.TCP)},
 					{label: "QUIC handshake", duration: measure.MeasuresCollection.Get(stats.QUIC)},
 					{label: "TLS handshake", duration: measure.MeasuresCollection.Get(stats.TLS)},
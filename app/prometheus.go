@@ -0,0 +1,259 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/net/sockettrace"
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prometheusBuckets are the upper bounds (in seconds) of the buckets of the latency histograms
+var prometheusBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type prometheusHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *prometheusHistogram) observe(seconds float64) {
+	for i, le := range prometheusBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+type prometheusMetrics struct {
+	target    string
+	protocol  string
+	address   string // address of the target pinged, when each of them is pinged
+	requests  uint64
+	failures  map[string]uint64
+	responses map[int]uint64
+	latencies map[stats.TimerType]*prometheusHistogram
+	tcpInfo   *sockettrace.TCPInfo // of the last successful request
+}
+
+// prometheusExporter exposes the metrics of one or several targets in the Prometheus text format
+type prometheusExporter struct {
+	mutex   sync.Mutex
+	metrics []*prometheusMetrics
+}
+
+func newPrometheusExporter() *prometheusExporter {
+	return &prometheusExporter{}
+}
+
+// startPrometheusExporter starts a prometheusExporter listening on a specific address
+func startPrometheusExporter(address string) (*prometheusExporter, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	exporter := newPrometheusExporter()
+	exporter.serve(listener)
+	return exporter, nil
+}
+
+// serve exposes the metrics on the /metrics endpoint of the listener
+func (exporter *prometheusExporter) serve(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+}
+
+// prometheusLogger decorates a PingLogger, it records the measures of a target into a prometheusExporter
+type prometheusLogger struct {
+	PingLogger
+	exporter *prometheusExporter
+	metrics  *prometheusMetrics
+}
+
+func newPrometheusLogger(logger PingLogger, exporter *prometheusExporter, target, protocol, address string) *prometheusLogger {
+	metrics := &prometheusMetrics{
+		target:    target,
+		protocol:  protocol,
+		address:   address,
+		failures:  make(map[string]uint64),
+		responses: make(map[int]uint64),
+		latencies: make(map[stats.TimerType]*prometheusHistogram),
+	}
+
+	exporter.mutex.Lock()
+	exporter.metrics = append(exporter.metrics, metrics)
+	exporter.mutex.Unlock()
+
+	return &prometheusLogger{PingLogger: logger, exporter: exporter, metrics: metrics}
+}
+
+func (logger *prometheusLogger) onMeasure(measure *HTTPMeasure) {
+	logger.record(measure)
+	logger.PingLogger.onMeasure(measure)
+}
+
+func (logger *prometheusLogger) record(measure *HTTPMeasure) {
+	logger.exporter.mutex.Lock()
+	defer logger.exporter.mutex.Unlock()
+
+	if measure.Cancelled {
+		return
+	}
+
+	metrics := logger.metrics
+	metrics.requests++
+
+	if measure.StatusCode != 0 {
+		metrics.responses[measure.StatusCode]++
+	}
+
+	if measure.IsFailure {
+		metrics.failures[measure.FailureCause]++
+		return
+	}
+
+	if measure.TCPInfo != nil {
+		metrics.tcpInfo = measure.TCPInfo
+	}
+
+	if measure.MeasuresCollection == nil {
+		return
+	}
+	for _, tt := range stats.TimerTypes {
+		if m := measure.MeasuresCollection.Get(tt); m.IsValid() {
+			h, ok := metrics.latencies[tt]
+			if !ok {
+				h = &prometheusHistogram{counts: make([]uint64, len(prometheusBuckets))}
+				metrics.latencies[tt] = h
+			}
+			h.observe(m.ToFloat(time.Second))
+		}
+	}
+}
+
+func (exporter *prometheusExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	exporter.writeMetrics(w)
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusLabels(pairs ...string) string {
+	var labels []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", pairs[i], prometheusLabelEscaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// labels returns the labels identifying the metrics of a target, followed by the pairs given
+func (metrics *prometheusMetrics) labels(pairs ...string) string {
+	labels := []string{"target", metrics.target, "protocol", metrics.protocol}
+	if metrics.address != "" {
+		labels = append(labels, "address", metrics.address)
+	}
+	return prometheusLabels(append(labels, pairs...)...)
+}
+
+func (exporter *prometheusExporter) writeMetrics(w io.Writer) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP http_ping_requests_total Number of requests sent.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE http_ping_requests_total counter\n")
+	for _, metrics := range exporter.metrics {
+		_, _ = fmt.Fprintf(w, "http_ping_requests_total%s %d\n", metrics.labels(), metrics.requests)
+	}
+
+	_, _ = fmt.Fprintf(w, "# HELP http_ping_failures_total Number of failed requests, by cause.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE http_ping_failures_total counter\n")
+	for _, metrics := range exporter.metrics {
+		causes := make([]string, 0, len(metrics.failures))
+		for cause := range metrics.failures {
+			causes = append(causes, cause)
+		}
+		sort.Strings(causes)
+		for _, cause := range causes {
+			_, _ = fmt.Fprintf(w, "http_ping_failures_total%s %d\n", metrics.labels("cause", cause), metrics.failures[cause])
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "# HELP http_ping_responses_total Number of responses received, by status code.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE http_ping_responses_total counter\n")
+	for _, metrics := range exporter.metrics {
+		codes := make([]int, 0, len(metrics.responses))
+		for code := range metrics.responses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			_, _ = fmt.Fprintf(w, "http_ping_responses_total%s %d\n", metrics.labels("code", strconv.Itoa(code)), metrics.responses[code])
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "# HELP http_ping_latency_seconds Latency of successful requests, by phase.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE http_ping_latency_seconds histogram\n")
+	for _, metrics := range exporter.metrics {
+		for _, tt := range stats.TimerTypes {
+			h, ok := metrics.latencies[tt]
+			if !ok {
+				continue
+			}
+			for i, le := range prometheusBuckets {
+				_, _ = fmt.Fprintf(w, "http_ping_latency_seconds_bucket%s %d\n", metrics.labels("phase", tt.String(), "le", strconv.FormatFloat(le, 'g', -1, 64)), h.counts[i])
+			}
+			_, _ = fmt.Fprintf(w, "http_ping_latency_seconds_bucket%s %d\n", metrics.labels("phase", tt.String(), "le", "+Inf"), h.count)
+			_, _ = fmt.Fprintf(w, "http_ping_latency_seconds_sum%s %g\n", metrics.labels("phase", tt.String()), h.sum)
+			_, _ = fmt.Fprintf(w, "http_ping_latency_seconds_count%s %d\n", metrics.labels("phase", tt.String()), h.count)
+		}
+	}
+
+	tcpGauges := []struct {
+		name, help string
+		value      func(info *sockettrace.TCPInfo) float64
+	}{
+		{"http_ping_tcp_rtt_seconds", "Round-trip time estimated by the kernel.", func(info *sockettrace.TCPInfo) float64 { return info.RTT.Seconds() }},
+		{"http_ping_tcp_rtt_variance_seconds", "Variance of the round-trip time estimated by the kernel.", func(info *sockettrace.TCPInfo) float64 { return info.RTTVar.Seconds() }},
+		{"http_ping_tcp_retransmits", "Segments retransmitted on the connection.", func(info *sockettrace.TCPInfo) float64 { return float64(info.Retransmits) }},
+		{"http_ping_tcp_congestion_window", "Congestion window of the connection, in segments.", func(info *sockettrace.TCPInfo) float64 { return float64(info.CongestionWindow) }},
+		{"http_ping_tcp_mss_bytes", "Maximum segment size of the connection.", func(info *sockettrace.TCPInfo) float64 { return float64(info.MSS) }},
+	}
+
+	for _, gauge := range tcpGauges {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s Last value observed, Linux only.\n", gauge.name, gauge.help)
+		_, _ = fmt.Fprintf(w, "# TYPE %s gauge\n", gauge.name)
+		for _, metrics := range exporter.metrics {
+			if metrics.tcpInfo != nil {
+				_, _ = fmt.Fprintf(w, "%s%s %g\n", gauge.name, metrics.labels(), gauge.value(metrics.tcpInfo))
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fever.ch/http-ping/stats"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusLogger(t *testing.T) {
+	config := &Config{}
+	exporter := newPrometheusExporter()
+	logger := newPrometheusLogger(newQuietLogger(config, &consoleLoggerMock{b: bytes.NewBufferString("")}, &PingerMock{}), exporter, "https://www.google.com", "auto", "")
+
+	mc := stats.NewMeasureRegistry()
+	mc.Set(stats.Total, stats.Measure(20*time.Millisecond))
+	mc.Set(stats.TCP, stats.Measure(3*time.Millisecond))
+
+	logger.onMeasure(&HTTPMeasure{StatusCode: 200, MeasuresCollection: mc})
+	logger.onMeasure(&HTTPMeasure{StatusCode: 503, IsFailure: true, FailureCause: "Server-side error", MeasuresCollection: stats.NewMeasureRegistry()})
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	out := recorder.Body.String()
+
+	for _, want := range []string{
+		`http_ping_requests_total{target="https://www.google.com",protocol="auto"} 2`,
+		`http_ping_failures_total{target="https://www.google.com",protocol="auto",cause="Server-side error"} 1`,
+		`http_ping_responses_total{target="https://www.google.com",protocol="auto",code="503"} 1`,
+		`http_ping_latency_seconds_bucket{target="https://www.google.com",protocol="auto",phase="total",le="0.025"} 1`,
+		`http_ping_latency_seconds_bucket{target="https://www.google.com",protocol="auto",phase="total",le="0.01"} 0`,
+		`http_ping_latency_seconds_count{target="https://www.google.com",protocol="auto",phase="tcp"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics should contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ParseProxy parses the URL of a proxy, HTTP and SOCKS5 proxies are supported. With SOCKS5 the target is always
+// resolved by the proxy, socks5h is accepted as a synonym.
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy `%s'", proxy)
+	}
+
+	switch u.Scheme {
+	case "http", "socks5":
+	case "socks5h":
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme `%s', should be http or socks5", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy `%s', host missing", proxy)
+	}
+	return u, nil
+}
+
+// newProxyFunc returns the function selecting the proxy of each request: the one of the config, none if proxies are
+// disabled or requests are sent over a UNIX socket, or otherwise the one defined by the environment. The proxy selected
+// is recorded in the measure context.
+func newProxyFunc(config *Config) (func(*http.Request) (*url.URL, error), error) {
+	proxy := http.ProxyFromEnvironment
+
+	if config.NoProxy || config.UnixSocket != "" {
+		proxy = func(*http.Request) (*url.URL, error) {
+			return nil, nil
+		}
+	} else if config.Proxy != "" {
+		u, err := ParseProxy(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if measureContext := measureContextFrom(req.Context()); measureContext != nil && u != nil {
+			measureContext.proxy = u
+			// plain HTTP requests are simply forwarded by HTTP proxies, otherwise a tunnel is needed
+			measureContext.tunnel = u.Scheme != "http" || req.URL.Scheme == "https"
+		}
+		return u, err
+	}, nil
+}
+
+// onProxyConnectResponse ends the tunnel phase once the proxy has answered the CONNECT request
+func onProxyConnectResponse(ctx context.Context, _ *url.URL, _ *http.Request, _ *http.Response) error {
+	if measureContext := measureContextFrom(ctx); measureContext != nil {
+		measureContext.endTunnel()
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fever.ch/http-ping/stats"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestProxy starts an HTTP proxy forwarding plain requests to forward and tunneling CONNECT requests to tunnel
+func newTestProxy(t *testing.T, forward http.Handler, tunnel string) *httptest.Server {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			forward.ServeHTTP(w, r)
+			return
+		}
+
+		target, err := net.Dial("tcp", tunnel)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		go func() {
+			_, _ = io.Copy(target, conn)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		_ = conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestHTTPProxy(t *testing.T) {
+	proxy := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "target.invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}), "")
+
+	webClient, err := NewWebClientBuilder(&Config{Target: "http://target.invalid/", Proxy: proxy.URL}, &RuntimeConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+
+	if measure.IsFailure || measure.StatusCode != http.StatusOK {
+		t.Fatalf("request through the proxy should have succeeded: %s", measure.FailureCause)
+	}
+	if !measure.MeasuresCollection.Get(stats.ProxyConnect).IsValid() || measure.MeasuresCollection.Get(stats.TCP).IsValid() {
+		t.Error("the connection to the proxy should replace the TCP handshake")
+	}
+	if measure.MeasuresCollection.Get(stats.Tunnel).IsValid() {
+		t.Error("plain HTTP requests should not be tunneled")
+	}
+}
+
+func TestConnectTunnel(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	proxy := newTestProxy(t, http.NotFoundHandler(), ts.Listener.Addr().String())
+
+	webClient, err := NewWebClientBuilder(&Config{Target: "https://target.invalid/", Proxy: proxy.URL, NoCheckCertificate: true}, &RuntimeConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+
+	if measure.IsFailure || measure.StatusCode != http.StatusOK {
+		t.Fatalf("request through the tunnel should have succeeded: %s", measure.FailureCause)
+	}
+	for _, tt := range []stats.TimerType{stats.ProxyConnect, stats.Tunnel, stats.TLS} {
+		if !measure.MeasuresCollection.Get(tt).IsValid() {
+			t.Errorf("phase %s should have been measured", tt)
+		}
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	if u, err := ParseProxy("socks5h://127.0.0.1:1080"); err != nil || u.Scheme != "socks5" {
+		t.Error("socks5h should be accepted as socks5")
+	}
+	for _, proxy := range []string{"ftp://127.0.0.1:21", "http://", "127.0.0.1:3128"} {
+		if _, err := ParseProxy(proxy); err == nil {
+			t.Errorf("proxy `%s' should be rejected", proxy)
+		}
+	}
+}
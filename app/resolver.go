@@ -17,81 +17,55 @@
 package app
 
 import (
-	"bytes"
-	"encoding/base64"
-	dns2 "fever.ch/http-ping/net/dns"
 	"fmt"
 	"github.com/domainr/dnsr"
-	"github.com/miekg/dns"
 	"net"
-	"net/http"
-	"strings"
+	"time"
 )
 
 type resolver struct {
 	config      *Config
-	cache       map[string]*net.IPAddr
+	cache       *DNSCache
 	dnsResolver *dnsr.Resolver
+	backend     BackendResolver
+	stub        *stubResolver
 }
 
-func newResolver(config *Config) *resolver {
+func newResolver(config *Config, runtimeConfig *RuntimeConfig) *resolver {
+	// the backend and the cache are normally shared by the resolvers of a session, so that the connections to the
+	// server are reused and the answers are cached once for all the workers
+	var backend BackendResolver
+	var cache *DNSCache
+	if runtimeConfig != nil {
+		backend, cache = runtimeConfig.DNSBackend, runtimeConfig.DNSCache
+	}
+	if backend == nil {
+		// an invalid DNS server is reported when the session is built
+		backend, _ = NewBackendResolver(config)
+	}
+	if cache == nil {
+		cache = NewDNSCache(config.DNSMinTTL, config.DNSMaxTTL)
+	}
+
 	return &resolver{
 		config:      config,
-		cache:       make(map[string]*net.IPAddr),
+		cache:       cache,
 		dnsResolver: dnsr.NewResolver(dnsr.WithCache(1024)),
+		backend:     backend,
 	}
 }
 
-func (resolver *resolver) resolveConn(addr string) (string, error) {
+// resolveConn resolves the host of addr (host:port), it returns the address to connect to and the DNS server that
+// answered, which is empty for IP addresses
+func (resolver *resolver) resolveConn(addr string) (string, string, error) {
 	if host, port, err := net.SplitHostPort(addr); err != nil {
-		return "", err
+		return "", "", err
+	} else if net.ParseIP(host) != nil {
+		return addr, "", nil
 	} else if resolved, err := resolver.resolve(host); err != nil {
-		return "", err
-	} else {
-		if strings.Contains(resolved.IP.String(), ":") {
-			return fmt.Sprintf("[%s]:%s", resolved, port), nil
-		}
-		return fmt.Sprintf("%s:%s", resolved, port), nil
-	}
-}
-
-func resolveWithSpecificServerQtypes(server string, host string, qtypes []uint16) ([]*net.IP, error) {
-	var ips []*net.IP
-
-	msg := new(dns.Msg)
-	msg.Id = dns.Id()
-	msg.RecursionDesired = true
-	msg.Question = []dns.Question{}
-
-	for _, qtype := range qtypes {
-		msg.Question = append(msg.Question, dns.Question{Name: host, Qtype: qtype, Qclass: dns.ClassINET})
-	}
-
-	c := new(dns.Client)
-
-	in, _, err := c.Exchange(msg, fmt.Sprintf("%s:53", server))
-
-	if err != nil {
-		return nil, err
-	}
-
-	for _, a := range in.Answer {
-		if ipv4, ok := a.(*dns.A); ok {
-			ips = append(ips, &ipv4.A)
-		} else if ipv6, ok := a.(*dns.AAAA); ok {
-			ips = append(ips, &ipv6.AAAA)
-		}
-	}
-	return ips, nil
-}
-
-func resolveWithSpecificServer(network, server string, host string) ([]*net.IP, error) {
-	if network == "ip4" {
-		return resolveWithSpecificServerQtypes(server, host, []uint16{dns.TypeA})
-	} else if network == "ip6" {
-		return resolveWithSpecificServerQtypes(server, host, []uint16{dns.TypeAAAA})
+		return "", "", err
 	} else {
-		return resolveWithSpecificServerQtypes(server, host, []uint16{dns.TypeAAAA, dns.TypeA})
+		return net.JoinHostPort(resolved.addrs[0].String(), port), resolved.server, nil
 	}
 }
 
@@ -99,60 +73,66 @@ func noSuchHostError(host string) error {
 	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
 }
 
-func (resolver *resolver) resolve(addr string) (*net.IPAddr, error) {
-	if val, ok := resolver.cache[addr]; ok {
-		return val, nil
+// resolve returns the resolution of addr, from the cache if enabled, the answers are recorded to notice their changes
+func (resolver *resolver) resolve(addr string) (resolution, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return resolution{addrs: []net.IP{ip}}, nil
 	}
 
-	resolvedAddr, err := resolver.actualResolve(addr)
-	if err != nil {
-		return nil, err
+	if resolver.config.CacheDNSRequests {
+		if cached, ok := resolver.cache.get(addr); ok {
+			return cached, nil
+		}
 	}
 
-	if resolver.config.CacheDNSRequests {
-		resolver.cache[addr] = resolvedAddr
+	resolved, err := resolver.actualResolve(addr)
+	if err != nil {
+		return resolution{}, err
 	}
-	return resolvedAddr, err
+
+	resolver.cache.put(addr, resolved)
+	return resolved, nil
 }
 
-func (resolver *resolver) actualResolve(addr string) (*net.IPAddr, error) {
+func (resolver *resolver) actualResolve(addr string) (resolution, error) {
 
 	if resolver.config.FullDNS {
 		var ip net.IP
+		var ttl time.Duration
 
-		if ip = net.ParseIP(addr); ip == nil {
-			if entries, err := resolver.fullResolveFromRoot(resolver.config.IPProtocol, addr); err == nil {
-				ip = net.ParseIP(*entries)
-			}
+		if rr, err := resolver.fullResolveFromRoot(resolver.config.IPProtocol, addr); err == nil {
+			ip, ttl = net.ParseIP(rr.Value), rr.TTL
 		}
 		if ip == nil {
-			return nil, noSuchHostError(addr)
+			return resolution{}, noSuchHostError(addr)
 		}
-		return &net.IPAddr{IP: ip}, nil
-	} else {
-		server := resolver.config.DNSServer
-		if server == "" {
-			hostServers, err := dns2.GetDNSServers()
-			if err != nil {
-				return nil, err
-			}
-			server = hostServers[0]
+		return resolution{addrs: []net.IP{ip}, ttl: ttl, server: "root servers"}, nil
+	} else if resolver.backend != nil {
+		msg, err := resolver.backend.Resolve(addr, qtypesOf(resolver.config.IPProtocol))
+		if err != nil {
+			return resolution{}, err
 		}
 
-		ip, err := resolveWithSpecificServer(resolver.config.IPProtocol, server, fmt.Sprintf("%s.", addr))
-		if err != nil {
-			return nil, err
+		ips := addressesOf(msg)
+		if len(ips) == 0 {
+			return resolution{}, noSuchHostError(addr)
 		}
 
-		if len(ip) == 0 {
-			return nil, noSuchHostError(addr)
+		return resolution{addrs: ips, ttl: ttlOf(msg), server: resolver.config.DNSServer}, nil
+	} else {
+		if resolver.stub == nil {
+			stub, err := newStubResolver(resolver.config.DNSServer)
+			if err != nil {
+				return resolution{}, err
+			}
+			resolver.stub = stub
 		}
 
-		return &net.IPAddr{IP: *ip[0]}, nil
+		return resolver.stub.lookup(addr, qtypesOf(resolver.config.IPProtocol))
 	}
 }
 
-func (resolver *resolver) fullResolveFromRoot(network, host string) (*string, error) {
+func (resolver *resolver) fullResolveFromRoot(network, host string) (*dnsr.RR, error) {
 	var qtypes []string
 
 	if network == "ip" {
@@ -168,13 +148,13 @@ func (resolver *resolver) fullResolveFromRoot(network, host string) (*string, er
 	return resolver.resolveRecu(host, qtypes)
 }
 
-func (resolver *resolver) resolveRecu(host string, qtypes []string) (*string, error) {
+func (resolver *resolver) resolveRecu(host string, qtypes []string) (*dnsr.RR, error) {
 
 	cnames := make(map[string]struct{})
 	for _, qtype := range qtypes {
 		for _, rr := range resolver.dnsResolver.Resolve(host, qtype) {
 			if rr.Type == qtype {
-				return &rr.Value, nil
+				return &rr, nil
 			} else if rr.Type == "CNAME" {
 				cnames[rr.Value] = struct{}{}
 			}
@@ -187,68 +167,3 @@ func (resolver *resolver) resolveRecu(host string, qtypes []string) (*string, er
 
 	return nil, fmt.Errorf("no host found: %s", host)
 }
-
-type BackendResolver interface {
-	Resolve(host string, qtype []uint16) (*dns.Msg, error)
-}
-
-func NewDoHResolver(url string) BackendResolver {
-	return &DoHResolver{doHEndpoint: url}
-}
-
-type DoHResolver struct {
-	doHEndpoint string
-}
-
-func (dohResolver *DoHResolver) Resolve(host string, qtypes []uint16) (*dns.Msg, error) {
-
-	msg := new(dns.Msg)
-	for _, qtype := range qtypes {
-		msg.Question = append(msg.Question, dns.Question{Name: dns.Fqdn(host), Qtype: qtype})
-		// shall i add  Qclass: dns.ClassINET?
-	}
-
-	// Convert the DNS message to wire format
-	wireMsg, err := msg.Pack()
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert the wire format message to base64 URL encoding
-	encodedMsg := base64.RawURLEncoding.EncodeToString(wireMsg)
-
-	// Create the DoH request
-	req, err := http.NewRequest("GET", dohResolver.doHEndpoint, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	q := req.URL.Query()
-	q.Add("dns", encodedMsg)
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set("accept", "application/dns-message")
-
-	// Execute the request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Read and unpack the response
-	respMsg := new(dns.Msg)
-	respBody := new(bytes.Buffer)
-	_, err = respBody.ReadFrom(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	err = respMsg.Unpack(respBody.Bytes())
-	if err != nil {
-		return nil, err
-	}
-	// Parse the response for TLSA records
-	return respMsg, nil
-}
-
-//  https://cloudflare-dns.com/dns-query
-//  https://dns.google/dns-query
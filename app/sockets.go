@@ -0,0 +1,90 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fever.ch/http-ping/net/sockopt"
+	"github.com/quic-go/quic-go"
+	"net"
+)
+
+// socketOptions returns the options applied to the sockets of the requests
+func socketOptions(config *Config) *sockopt.Options {
+	return &sockopt.Options{
+		Interface:         config.Interface,
+		TOS:               config.TOS,
+		Mark:              config.SOMark,
+		RcvBuf:            config.RcvBuf,
+		SndBuf:            config.SndBuf,
+		CongestionControl: config.CongestionControl,
+		NoDelay:           config.TCPNoDelay,
+	}
+}
+
+// newDialer returns the dialer of the TCP connections, bound to the source address of config and applying its socket
+// options
+func newDialer(config *Config) *net.Dialer {
+	dialer := &net.Dialer{Control: socketOptions(config).Control()}
+	if config.SourceAddress != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(config.SourceAddress)}
+	}
+	return dialer
+}
+
+// dialQUIC establishes a QUIC connection, from a UDP socket of its own when it has to be bound to a source address or
+// when socket options are set, the socket is then closed with the connection
+func dialQUIC(ctx context.Context, config *Config, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	if config.SourceAddress == "" && !socketOptions(config).IsSet() {
+		return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := listenUDP(ctx, config, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := quic.DialEarly(ctx, conn, udpAddr, tlsCfg, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-connection.Context().Done()
+		_ = conn.Close()
+	}()
+	return connection, nil
+}
+
+// listenUDP opens the UDP socket of a QUIC connection to remote, of the same address family: the options of the IPv4
+// packets (i.e. the type of service) would not be applied to the packets of a dual-stack socket
+func listenUDP(ctx context.Context, config *Config, remote *net.UDPAddr) (net.PacketConn, error) {
+	network := "udp6"
+	if remote.IP.To4() != nil {
+		network = "udp4"
+	}
+
+	listenConfig := net.ListenConfig{Control: socketOptions(config).Control()}
+	return listenConfig.ListenPacket(ctx, network, net.JoinHostPort(config.SourceAddress, "0"))
+}
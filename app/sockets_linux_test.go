@@ -0,0 +1,42 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"golang.org/x/sys/unix"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestQUICSocketTOS(t *testing.T) {
+	conn, err := listenUDP(context.Background(), &Config{TOS: 184}, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 443})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rawConn, _ := conn.(syscall.Conn).SyscallConn()
+	var tos int
+	_ = rawConn.Control(func(fd uintptr) {
+		tos, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+	})
+	if err != nil || tos != 184 {
+		t.Fatalf("type of service of the QUIC socket should be 184, not %d", tos)
+	}
+}
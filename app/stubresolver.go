@@ -0,0 +1,160 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	dns2 "fever.ch/http-ping/net/dns"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+// hostsTTL is the time the addresses found in the hosts file are cached
+const hostsTTL = time.Minute
+
+// stubResolver resolves names like the resolver of the system: the hosts file is looked up first, then the configured
+// servers are queried in turn, with a timeout per attempt, for each of the names built with the search domains
+type stubResolver struct {
+	servers  []string // host:port
+	search   []string
+	ndots    int
+	timeout  time.Duration
+	attempts int
+	hosts    map[string][]net.IP
+}
+
+// newStubResolver builds a resolver using the configuration of the system (/etc/resolv.conf and /etc/hosts), or only
+// its options if a specific server is given, the hosts file is then ignored
+func newStubResolver(server string) (*stubResolver, error) {
+	config, err := dns2.GetClientConfig()
+	if err != nil {
+		if server == "" {
+			return nil, err
+		}
+		config = &dns.ClientConfig{Port: "53", Ndots: 1, Timeout: 5, Attempts: 2}
+	}
+
+	stub := &stubResolver{
+		search:   config.Search,
+		ndots:    config.Ndots,
+		timeout:  time.Duration(config.Timeout) * time.Second,
+		attempts: config.Attempts,
+	}
+
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		stub.servers = []string{server}
+	} else {
+		for _, s := range config.Servers {
+			stub.servers = append(stub.servers, net.JoinHostPort(s, config.Port))
+		}
+		// a missing hosts file is not an error
+		stub.hosts, _ = dns2.ReadHosts(dns2.HostsPath)
+	}
+
+	if len(stub.servers) == 0 {
+		return nil, errors.New("no DNS server configured")
+	}
+	return stub, nil
+}
+
+// lookup resolves the addresses of host for the types of records given, the server that answered is reported
+func (stub *stubResolver) lookup(host string, qtypes []uint16) (resolution, error) {
+	if ips := stub.lookupHosts(host, qtypes); len(ips) > 0 {
+		return resolution{addrs: ips, ttl: hostsTTL, server: dns2.HostsPath}, nil
+	}
+
+	for _, name := range stub.candidates(host) {
+		msg, server, err := stub.query(name, qtypes)
+		if err != nil {
+			return resolution{}, err
+		}
+		if ips := addressesOf(msg); len(ips) > 0 {
+			return resolution{addrs: ips, ttl: ttlOf(msg), server: server}, nil
+		}
+	}
+	return resolution{}, noSuchHostError(host)
+}
+
+// lookupHosts returns the addresses of host found in the hosts file
+func (stub *stubResolver) lookupHosts(host string, qtypes []uint16) []net.IP {
+	var ips []net.IP
+	for _, qtype := range qtypes {
+		for _, ip := range stub.hosts[strings.ToLower(strings.TrimSuffix(host, "."))] {
+			if (ip.To4() != nil) == (qtype == dns.TypeA) {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// candidates returns the names to be queried for host, in order: names with at least ndots dots are tried as is
+// first, absolute names (with a trailing dot) are never completed with the search domains
+func (stub *stubResolver) candidates(host string) []string {
+	if strings.HasSuffix(host, ".") {
+		return []string{host}
+	}
+
+	var names []string
+	for _, domain := range stub.search {
+		names = append(names, dns.Fqdn(host+"."+strings.TrimSuffix(domain, ".")))
+	}
+
+	if strings.Count(host, ".") >= stub.ndots {
+		return append([]string{dns.Fqdn(host)}, names...)
+	}
+	return append(names, dns.Fqdn(host))
+}
+
+// query sends the queries for name to each server in turn, as many times as configured, until one of them answers
+func (stub *stubResolver) query(name string, qtypes []uint16) (*dns.Msg, string, error) {
+	var err error
+	for attempt := 0; attempt < max(stub.attempts, 1); attempt++ {
+		for _, server := range stub.servers {
+			var msg *dns.Msg
+			if msg, err = resolveQtypes(stub.exchangeWith(server), name, qtypes); err == nil {
+				return msg, server, nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("no DNS server could resolve `%s': %w", strings.TrimSuffix(name, "."), err)
+}
+
+// exchangeWith returns the function sending queries to server over UDP, truncated answers are queried again over TCP
+func (stub *stubResolver) exchangeWith(server string) func(*dns.Msg) (*dns.Msg, error) {
+	return func(msg *dns.Msg) (*dns.Msg, error) {
+		answer, _, err := (&dns.Client{Net: "udp", Timeout: stub.timeout}).Exchange(msg, server)
+		if err == nil && answer.Truncated {
+			answer, _, err = (&dns.Client{Net: "tcp", Timeout: stub.timeout}).Exchange(msg, server)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// another server might know better
+		if answer.Rcode != dns.RcodeSuccess && answer.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("server %s answered %s", server, dns.RcodeToString[answer.Rcode])
+		}
+		return answer, nil
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	dns2 "fever.ch/http-ping/net/dns"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStubTestServer starts a DNS server over UDP and TCP knowing only the names of example.com, its UDP answers are
+// truncated when truncate is set, the networks of the queries are sent to networks
+func newStubTestServer(t *testing.T, truncate bool, networks chan<- string) string {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, query *dns.Msg) {
+		network := w.RemoteAddr().Network()
+		select {
+		case networks <- network:
+		default:
+		}
+
+		answer := dnsTestHandler(query)
+		if !strings.HasSuffix(query.Question[0].Name, ".example.com.") {
+			answer = new(dns.Msg)
+			answer.SetRcode(query, dns.RcodeNameError)
+		} else if truncate && network == "udp" {
+			answer.Answer, answer.Truncated = nil, true
+		}
+		_ = w.WriteMsg(answer)
+	})
+
+	for _, server := range []*dns.Server{{PacketConn: packetConn, Handler: handler}, {Listener: listener, Handler: handler}} {
+		server := server
+		go func() { _ = server.ActivateAndServe() }()
+		t.Cleanup(func() { _ = server.Shutdown() })
+	}
+	return packetConn.LocalAddr().String()
+}
+
+// deadServer returns the address of a closed UDP port
+func deadServer(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestStubResolverFailover(t *testing.T) {
+	server := newStubTestServer(t, false, nil)
+	stub := &stubResolver{servers: []string{deadServer(t), server}, search: []string{"example.com"}, ndots: 1, timeout: time.Second, attempts: 1}
+
+	resolved, err := stub.lookup("www", qtypesOf("ip4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resolved.addrs[0].Equal(net.IPv4(127, 0, 0, 1)) || resolved.server != server || resolved.ttl != time.Minute {
+		t.Errorf("www should have been resolved through the search domain by %s, got %v by %s", server, resolved.addrs, resolved.server)
+	}
+
+	var dnsErr *net.DNSError
+	if _, err = stub.lookup("www.example.org.", qtypesOf("ip4")); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("unknown names should not be found, got %v", err)
+	}
+
+	stub.servers = []string{deadServer(t)}
+	if _, err = stub.lookup("www.example.com", qtypesOf("ip4")); err == nil || errors.As(err, &dnsErr) {
+		t.Errorf("the failure of all the servers should be reported, got %v", err)
+	}
+}
+
+func TestStubResolverTruncation(t *testing.T) {
+	networks := make(chan string, 2)
+	stub := &stubResolver{servers: []string{newStubTestServer(t, true, networks)}, timeout: time.Second, attempts: 1}
+
+	if resolved, err := stub.lookup("www.example.com.", qtypesOf("ip4")); err != nil || len(resolved.addrs) != 1 {
+		t.Fatalf("truncated answers should be queried again over TCP: %v", err)
+	}
+	if first, second := <-networks, <-networks; first != "udp" || second != "tcp" {
+		t.Errorf("the query should have been sent over UDP then TCP, not %s then %s", first, second)
+	}
+}
+
+func TestStubResolverHosts(t *testing.T) {
+	hosts, err := dns2.ParseHosts(strings.NewReader("# local names\n127.0.0.2 Intranet intranet.local # comment\n::1 intranet\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubResolver{servers: []string{deadServer(t)}, timeout: time.Second, attempts: 1, hosts: hosts}
+
+	resolved, err := stub.lookup("intranet", qtypesOf("ip"))
+	if err != nil || len(resolved.addrs) != 2 || resolved.server != dns2.HostsPath || resolved.ttl != hostsTTL {
+		t.Fatalf("intranet should have been found in the hosts file, got %v by %s (%v)", resolved.addrs, resolved.server, err)
+	}
+	if resolved.addrs[0].To4() != nil {
+		t.Error("IPv6 addresses should come first")
+	}
+}
+
+func TestStubResolverCandidates(t *testing.T) {
+	stub := &stubResolver{search: []string{"a.example", "b.example."}, ndots: 2}
+
+	for host, expected := range map[string]string{
+		"www":             "www.a.example. www.b.example. www.",
+		"www.example.com": "www.example.com. www.example.com.a.example. www.example.com.b.example.",
+		"www.":            "www.",
+	} {
+		if candidates := strings.Join(stub.candidates(host), " "); candidates != expected {
+			t.Errorf("candidates of %s should be %s, not %s", host, expected, candidates)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fever.ch/http-ping/stats"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Thresholds are the objectives checked at the end of a run, the run fails if any of them is breached. Zero values
+// (and a nil MaxLoss) are not checked.
+type Thresholds struct {
+	MaxLoss       *float64
+	MaxAvg        time.Duration
+	MaxP99        time.Duration
+	MinThroughput float64
+}
+
+// IsSet returns true if at least one threshold has to be checked
+func (t *Thresholds) IsSet() bool {
+	return t.MaxLoss != nil || t.MaxAvg > 0 || t.MaxP99 > 0 || t.MinThroughput > 0
+}
+
+// ThresholdError is returned by HTTPPing.Run when some thresholds are breached
+type ThresholdError struct {
+	Breaches []string
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("thresholds breached: %s", strings.Join(e.Breaches, "; "))
+}
+
+type thresholdCheck struct {
+	name   string
+	value  string
+	limit  string
+	passed bool
+}
+
+func (c thresholdCheck) String() string {
+	if c.passed {
+		return fmt.Sprintf("%s %s within %s", c.name, c.value, c.limit)
+	}
+	return fmt.Sprintf("%s %s breaches %s", c.name, c.value, c.limit)
+}
+
+// checkThresholds evaluates the thresholds against the statistics of a run
+func checkThresholds(t *Thresholds, m *measures) []thresholdCheck {
+	var checks []thresholdCheck
+
+	ms := func(d stats.Measure) string {
+		if !d.IsValid() {
+			return "n/a"
+		}
+		return fmt.Sprintf("%.1f ms", d.ToFloat(time.Millisecond))
+	}
+
+	if t.MaxLoss != nil {
+		loss := m.lossRate()
+		checks = append(checks, thresholdCheck{
+			name:   "loss",
+			value:  fmt.Sprintf("%.1f%%", loss*100),
+			limit:  fmt.Sprintf("max %.1f%%", *t.MaxLoss*100),
+			passed: m.attempts > 0 && loss <= *t.MaxLoss,
+		})
+	}
+
+	if t.MaxAvg > 0 {
+		avg := m.latencies.Average()
+		checks = append(checks, thresholdCheck{
+			name:   "average latency",
+			value:  ms(avg),
+			limit:  fmt.Sprintf("max %s", ms(stats.Measure(t.MaxAvg))),
+			passed: avg.IsValid() && avg <= stats.Measure(t.MaxAvg),
+		})
+	}
+
+	if t.MaxP99 > 0 {
+		p99 := m.latencies.Quantile(0.99)
+		checks = append(checks, thresholdCheck{
+			name:   "p99 latency",
+			value:  ms(p99),
+			limit:  fmt.Sprintf("max %s", ms(stats.Measure(t.MaxP99))),
+			passed: p99.IsValid() && p99 <= stats.Measure(t.MaxP99),
+		})
+	}
+
+	if t.MinThroughput > 0 {
+		throughput := m.throughput()
+		checks = append(checks, thresholdCheck{
+			name:   "throughput",
+			value:  fmt.Sprintf("%.1f queries/sec", throughput),
+			limit:  fmt.Sprintf("min %.1f queries/sec", t.MinThroughput),
+			passed: throughput >= t.MinThroughput,
+		})
+	}
+
+	return checks
+}
+
+// evaluateThresholds checks the thresholds of a run, reports the outcome through its logger and returns the breaches
+func evaluateThresholds(config *Config, logger PingLogger) []string {
+	if !config.Thresholds.IsSet() {
+		return nil
+	}
+
+	checks := checkThresholds(&config.Thresholds, logger.getMeasures())
+	logger.onThresholds(checks)
+
+	var breaches []string
+	for _, check := range checks {
+		if !check.passed {
+			breaches = append(breaches, check.String())
+		}
+	}
+	return breaches
+}
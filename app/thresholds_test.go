@@ -0,0 +1,57 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fever.ch/http-ping/stats"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	m := &measures{attempts: 100, successes: 98, latencies: stats.NewHistogram()}
+	for i := 1; i <= 98; i++ {
+		m.latencies.Record(stats.Measure(time.Duration(i) * time.Millisecond))
+	}
+
+	maxLoss := 0.01
+	checks := checkThresholds(&Thresholds{MaxLoss: &maxLoss, MaxAvg: 100 * time.Millisecond, MaxP99: 50 * time.Millisecond}, m)
+
+	if len(checks) != 3 {
+		t.Fatalf("3 checks expected, got %d", len(checks))
+	}
+	if checks[0].passed || !checks[1].passed || checks[2].passed {
+		t.Fatalf("unexpected outcome of the checks: %v", checks)
+	}
+}
+
+func TestHTTPPingThresholdError(t *testing.T) {
+	b := bytes.NewBufferString("")
+	instance, _ := NewHTTPPing(&Config{Count: 10, Thresholds: Thresholds{MaxAvg: time.Millisecond}}, &consoleLoggerMock{b: b})
+	instance.(*httpPingImpl).pinger = &PingerMock{}
+
+	var thresholdError *ThresholdError
+	if err := instance.Run(); !errors.As(err, &thresholdError) {
+		t.Fatalf("a threshold error was expected, got %v", err)
+	}
+	if !strings.Contains(b.String(), "FAILED: average latency") {
+		t.Fatal("the breach is not reported")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+	"strings"
+)
+
+// Client certificate types supported by HTTPPing
+const (
+	CertTypePEM = "PEM"
+	CertTypeP12 = "P12"
+)
+
+// newTLSConfig builds the TLS configuration shared by the TCP and QUIC transports
+func newTLSConfig(config *Config, runtimeConfig *RuntimeConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ClientSessionCache: runtimeConfig.TLSSessionCache,
+		InsecureSkipVerify: config.NoCheckCertificate,
+		ServerName:         config.TLSServerName,
+		MinVersion:         config.TLSMinVersion,
+		MaxVersion:         config.TLSMaxVersion,
+		CipherSuites:       config.CipherSuites,
+		CurvePreferences:   config.Curves,
+		NextProtos:         nextProtos(config),
+	}
+
+	if config.ClientCert != "" {
+		cert, err := loadClientCertificate(config)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CACert != "" || config.CAPath != "" {
+		pool, err := loadCertPool(config.CACert, config.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("CA certificates: %s", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// nextProtos returns the protocols offered through ALPN, h2 is replaced by http/1.1 when HTTP/1.1 is forced as the
+// transport would not be able to speak it
+func nextProtos(config *Config) []string {
+	if !config.HTTP1 || len(config.ALPN) == 0 {
+		return config.ALPN
+	}
+	var protos []string
+	for _, proto := range config.ALPN {
+		if proto != "h2" && proto != "http/1.1" {
+			protos = append(protos, proto)
+		}
+	}
+	return append(protos, "http/1.1")
+}
+
+// certType returns the type of the client certificate, as specified or guessed from the extension of its file
+func certType(config *Config) string {
+	if config.ClientCertType != "" {
+		return strings.ToUpper(config.ClientCertType)
+	}
+	switch strings.ToLower(filepath.Ext(config.ClientCert)) {
+	case ".p12", ".pfx":
+		return CertTypeP12
+	default:
+		return CertTypePEM
+	}
+}
+
+func loadClientCertificate(config *Config) (tls.Certificate, error) {
+	data, err := os.ReadFile(config.ClientCert)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if certType(config) == CertTypeP12 {
+		key, cert, caCerts, err := pkcs12.DecodeChain(data, config.ClientCertPassword)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		// the intermediate certificates of the file are sent along the client certificate
+		chain := [][]byte{cert.Raw}
+		for _, caCert := range caCerts {
+			chain = append(chain, caCert.Raw)
+		}
+		return tls.Certificate{Certificate: chain, PrivateKey: key, Leaf: cert}, nil
+	}
+
+	// without a key file, the key is expected in the certificate file
+	keyData := data
+	if config.ClientKey != "" {
+		if keyData, err = os.ReadFile(config.ClientKey); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(data, keyData)
+}
+
+// loadCertPool builds a pool from a bundle of PEM certificates and/or a directory of PEM certificates
+func loadCertPool(file, dir string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in %s", file)
+		}
+	}
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			// files which are not PEM certificates are ignored, like in OpenSSL hashed directories
+			if data, err := os.ReadFile(filepath.Join(dir, entry.Name())); err == nil {
+				found = pool.AppendCertsFromPEM(data) || found
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no certificate found in %s", dir)
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newClientCertificate generates a CA and a client certificate signed by it, the client certificate and its key are
+// written in dir
+func newClientCertificate(t *testing.T, dir string) *x509.Certificate {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "http-ping test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	ca, _ := x509.ParseCertificate(caDER)
+
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "http-ping"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	clientDER, _ := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	keyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", clientDER)
+	writePEM(t, filepath.Join(dir, "client.key"), "EC PRIVATE KEY", keyDER)
+	return ca
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newClientCertificate(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	// the server is trusted through a custom CA bundle
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", ts.Certificate().Raw)
+
+	config := &Config{
+		Target:     ts.URL,
+		ConnTarget: ts.Listener.Addr().String(),
+		ClientCert: filepath.Join(dir, "client.pem"),
+		ClientKey:  filepath.Join(dir, "client.key"),
+		CACert:     filepath.Join(dir, "server.pem"),
+	}
+	webClient, err := NewWebClientBuilder(config, &RuntimeConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if measure := webClient.NewInstance().DoMeasure(context.Background(), false); measure.IsFailure {
+		t.Fatalf("request with a client certificate failed: %s", measure.FailureCause)
+	}
+
+	configWithoutCert := *config
+	configWithoutCert.ClientCert, configWithoutCert.ClientKey = "", ""
+	webClient, _ = NewWebClientBuilder(&configWithoutCert, &RuntimeConfig{}, nil)
+	if measure := webClient.NewInstance().DoMeasure(context.Background(), false); !measure.IsFailure {
+		t.Fatal("request without a client certificate should have failed")
+	}
+}
+
+func TestP12ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	newClientCertificate(t, dir)
+
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(pair.Certificate[0])
+
+	// modern files are encrypted with AES (PBES2)
+	pfx, err := pkcs12.Modern.Encode(pair.PrivateKey, cert, nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "client.p12")
+	_ = os.WriteFile(path, pfx, 0o600)
+
+	if loaded, err := loadClientCertificate(&Config{ClientCert: path, ClientCertPassword: "secret"}); err != nil || loaded.Leaf.Subject.CommonName != "http-ping" {
+		t.Fatalf("PKCS#12 client certificate not loaded: %v", err)
+	}
+	if _, err := loadClientCertificate(&Config{ClientCert: path, ClientCertPassword: "wrong"}); err == nil {
+		t.Fatal("a wrong password should be rejected")
+	}
+}
+
+func TestCAPath(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0o600)
+
+	if _, err := newTLSConfig(&Config{CAPath: dir}, &RuntimeConfig{}); err == nil {
+		t.Fatal("a directory without certificates should be rejected")
+	}
+
+	ca := newClientCertificate(t, t.TempDir())
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+
+	if tlsConfig, err := newTLSConfig(&Config{CAPath: dir}, &RuntimeConfig{}); err != nil || tlsConfig.RootCAs == nil {
+		t.Fatalf("CA path not taken in account: %v", err)
+	}
+}
+
+func TestTLSPinning(t *testing.T) {
+	serverNames := make(chan string, 10)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverNames <- hello.ServerName
+			return nil, nil
+		},
+	}
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	config := &Config{
+		Target:             ts.URL,
+		ConnTarget:         ts.Listener.Addr().String(),
+		NoCheckCertificate: true,
+		TLSServerName:      "backend.example.com",
+		CipherSuites:       []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	webClient, _ := NewWebClientBuilder(config, &RuntimeConfig{}, nil)
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+	if measure.IsFailure || measure.TLSState.CipherSuite != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("the cipher suite was not enforced: %s", measure.FailureCause)
+	}
+	if sni := <-serverNames; sni != "backend.example.com" {
+		t.Fatalf("the server name %s was sent instead of the one enforced", sni)
+	}
+
+	configTLS13 := *config
+	configTLS13.TLSMinVersion = tls.VersionTLS13
+	webClient, _ = NewWebClientBuilder(&configTLS13, &RuntimeConfig{}, nil)
+	if measure := webClient.NewInstance().DoMeasure(context.Background(), false); !measure.IsFailure {
+		t.Fatal("TLS 1.3 is not supported by the server, the request should have failed")
+	}
+}
+
+func TestTLSResumption(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	config := &Config{Target: ts.URL, ConnTarget: ts.Listener.Addr().String(), NoCheckCertificate: true, DisableKeepAlive: true, TLSResumption: true}
+	runtimeConfig := &RuntimeConfig{TLSSessionCache: tls.NewLRUClientSessionCache(0)}
+	webClient, _ := NewWebClientBuilder(config, runtimeConfig, nil)
+	client := webClient.NewInstance()
+
+	if measure := client.DoMeasure(context.Background(), false); measure.IsFailure || measure.TLSResumed {
+		t.Fatal("the first handshake should be a full one")
+	}
+	if measure := client.DoMeasure(context.Background(), false); measure.IsFailure || !measure.TLSResumed {
+		t.Fatal("the second handshake should resume the session of the first one")
+	}
+}
+
+func TestALPN(t *testing.T) {
+	tlsConfig, _ := newTLSConfig(&Config{ALPN: []string{"h2", "acme"}}, &RuntimeConfig{})
+	if !reflect.DeepEqual(tlsConfig.NextProtos, []string{"h2", "acme"}) {
+		t.Fatalf("the protocols should be offered as specified, got %v", tlsConfig.NextProtos)
+	}
+
+	tlsConfig, _ = newTLSConfig(&Config{ALPN: []string{"h2", "acme"}, HTTP1: true}, &RuntimeConfig{})
+	if !reflect.DeepEqual(tlsConfig.NextProtos, []string{"acme", "http/1.1"}) {
+		t.Fatalf("h2 should be replaced by http/1.1 when HTTP/1.1 is forced, got %v", tlsConfig.NextProtos)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"golang.org/x/crypto/ocsp"
+	"math"
+	"strings"
+	"time"
+)
+
+// tlsInfo is the description of a TLS session and of the certificates presented by the server
+type tlsInfo struct {
+	version      string
+	cipherSuite  string
+	alpn         string
+	group        string // key exchange group, only known when a single one is offered
+	resumed      bool
+	ocspStapled  bool
+	ocspStatus   string
+	certificates []certificateInfo
+}
+
+type certificateInfo struct {
+	subject  string
+	sans     []string
+	issuer   string
+	notAfter time.Time
+	daysLeft int
+	expiring bool
+}
+
+var ocspStatuses = map[int]string{
+	ocsp.Good:    "good",
+	ocsp.Revoked: "revoked",
+	ocsp.Unknown: "unknown",
+}
+
+// newTLSInfo describes a TLS session, certificates expiring within expiryWarning are flagged
+func newTLSInfo(state *tls.ConnectionState, expiryWarning time.Duration, now time.Time) *tlsInfo {
+	info := &tlsInfo{
+		version:     tls.VersionName(state.Version),
+		cipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		alpn:        state.NegotiatedProtocol,
+		resumed:     state.DidResume,
+		ocspStapled: len(state.OCSPResponse) > 0,
+	}
+
+	if info.ocspStapled {
+		info.ocspStatus = "unverified"
+		if len(state.PeerCertificates) > 1 {
+			if res, err := ocsp.ParseResponseForCert(state.OCSPResponse, state.PeerCertificates[0], state.PeerCertificates[1]); err == nil {
+				info.ocspStatus = ocspStatuses[res.Status]
+			}
+		}
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.certificates = append(info.certificates, newCertificateInfo(cert, expiryWarning, now))
+	}
+	return info
+}
+
+func newCertificateInfo(cert *x509.Certificate, expiryWarning time.Duration, now time.Time) certificateInfo {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	left := cert.NotAfter.Sub(now)
+	return certificateInfo{
+		subject:  cert.Subject.String(),
+		sans:     sans,
+		issuer:   cert.Issuer.String(),
+		notAfter: cert.NotAfter,
+		daysLeft: int(math.Floor(left.Hours() / 24)),
+		expiring: left < expiryWarning,
+	}
+}
+
+// reportTLSInfo reports the TLS session of the first measure done over TLS, when requested
+func reportTLSInfo(config *Config, logger PingLogger, measure *HTTPMeasure) {
+	m := logger.getMeasures()
+	if !config.TLSInfo || m.tlsInfoReported || measure.IsFailure || measure.TLSState == nil {
+		return
+	}
+	m.tlsInfoReported = true
+
+	info := newTLSInfo(measure.TLSState, config.CertExpiryWarning, time.Now())
+	// the crypto/tls package does not expose the negotiated group, it can only be the one offered if it is pinned
+	if len(config.Curves) == 1 {
+		info.group = config.Curves[0].String()
+	}
+	logger.onTLSInfo(info)
+}
+
+func (logger *quietLogger) onTLSInfo(info *tlsInfo) {
+	yesNo := map[bool]string{true: "yes", false: "no"}
+
+	_, _ = logger.Printf("\n--- TLS information ---\n")
+	_, _ = logger.Printf("version: %s\n", info.version)
+	_, _ = logger.Printf("cipher suite: %s\n", info.cipherSuite)
+	if info.alpn != "" {
+		_, _ = logger.Printf("ALPN: %s\n", info.alpn)
+	}
+	if info.group != "" {
+		_, _ = logger.Printf("key exchange group: %s\n", info.group)
+	}
+	_, _ = logger.Printf("session resumed: %s\n", yesNo[info.resumed])
+	if info.ocspStapled {
+		_, _ = logger.Printf("OCSP stapling: yes (%s)\n", info.ocspStatus)
+	} else {
+		_, _ = logger.Printf("OCSP stapling: no\n")
+	}
+
+	_, _ = logger.Printf("certificate chain:\n")
+	for i, cert := range info.certificates {
+		_, _ = logger.Printf("  [%d] subject: %s\n", i, cert.subject)
+		if len(cert.sans) > 0 {
+			_, _ = logger.Printf("      SANs: %s\n", strings.Join(cert.sans, ", "))
+		}
+		_, _ = logger.Printf("      issuer: %s\n", cert.issuer)
+		_, _ = logger.Printf("      expires: %s (%d days)", cert.notAfter.Format(time.DateOnly), cert.daysLeft)
+		if cert.expiring {
+			_, _ = logger.Printf(" WARNING: expires within %.0f days", logger.config.CertExpiryWarning.Hours()/24)
+		}
+		_, _ = logger.Printf("\n")
+	}
+	_, _ = logger.Printf("\n")
+}
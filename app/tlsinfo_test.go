@@ -0,0 +1,79 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSInfo(t *testing.T) {
+	ca := newClientCertificate(t, t.TempDir())
+
+	state := &tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		NegotiatedProtocol: "h2",
+		PeerCertificates:   []*x509.Certificate{ca},
+	}
+	info := newTLSInfo(state, 14*24*time.Hour, time.Now())
+
+	if info.version != "TLS 1.3" || info.cipherSuite != "TLS_AES_128_GCM_SHA256" || info.alpn != "h2" || info.ocspStapled {
+		t.Fatalf("unexpected session description: %+v", info)
+	}
+	if len(info.certificates) != 1 || info.certificates[0].subject != "CN=http-ping test CA" || !info.certificates[0].expiring {
+		t.Fatalf("unexpected certificates description: %+v", info.certificates)
+	}
+}
+
+func TestTLSInfoReport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	config := &Config{Target: ts.URL, ConnTarget: ts.Listener.Addr().String(), NoCheckCertificate: true, TLSInfo: true, CertExpiryWarning: 14 * 24 * time.Hour}
+	webClient, _ := NewWebClientBuilder(config, &RuntimeConfig{}, nil)
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+	if measure.TLSState == nil {
+		t.Fatal("the TLS session should be part of the measure")
+	}
+
+	b := bytes.NewBufferString("")
+	logger := newQuietLogger(config, &consoleLoggerMock{b: b}, &PingerMock{})
+	reportTLSInfo(config, logger, measure)
+	reportTLSInfo(config, logger, measure)
+
+	if strings.Count(b.String(), "--- TLS information ---") != 1 || !strings.Contains(b.String(), "example.com") {
+		t.Fatalf("the TLS session should be reported once, got:\n%s", b.String())
+	}
+	if strings.Contains(b.String(), "key exchange group") {
+		t.Fatal("the key exchange group should only be reported when a single one is offered")
+	}
+
+	config.Curves = []tls.CurveID{tls.X25519}
+	b.Reset()
+	reportTLSInfo(config, newQuietLogger(config, &consoleLoggerMock{b: b}, &PingerMock{}), measure)
+	if !strings.Contains(b.String(), "key exchange group: X25519") {
+		t.Fatalf("the key exchange group offered should be reported, got:\n%s", b.String())
+	}
+}
@@ -17,12 +17,13 @@
 package app
 
 import (
+	"context"
 	"net/url"
 )
 
 // WebClient represents an HTTP/S clientBuilder designed to do performance analysis
 type WebClient interface {
-	DoMeasure(followRedirect bool) *HTTPMeasure
+	DoMeasure(ctx context.Context, followRedirect bool) *HTTPMeasure
 
 	GetURL() *url.URL
 }
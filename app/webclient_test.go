@@ -17,9 +17,14 @@
 package app
 
 import (
+	"context"
+	"fever.ch/http-ping/stats"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 )
 
@@ -43,14 +48,14 @@ func TestWithEmbeddedWebServer(t *testing.T) {
 	var measure *HTTPMeasure
 
 	webClient, _ = NewWebClientBuilder(&Config{Target: fmt.Sprintf("%s/500", url), NoCheckCertificate: true}, &RuntimeConfig{}, nil)
-	measure = webClient.NewInstance().DoMeasure(false)
+	measure = webClient.NewInstance().DoMeasure(context.Background(), false)
 
 	if !measure.IsFailure || measure.StatusCode != 500 {
 		t.Errorf("Request to server should have failed, 500")
 	}
 
 	webClient, _ = NewWebClientBuilder(&Config{Target: fmt.Sprintf("%s/200", url), NoCheckCertificate: true}, &RuntimeConfig{}, nil)
-	measure = webClient.NewInstance().DoMeasure(false)
+	measure = webClient.NewInstance().DoMeasure(context.Background(), false)
 
 	if measure.IsFailure || measure.StatusCode != 200 {
 		t.Errorf("Request to server should have succeed, 200")
@@ -58,10 +63,84 @@ func TestWithEmbeddedWebServer(t *testing.T) {
 
 	ts.Close()
 
-	measure = webClient.NewInstance().DoMeasure(false)
+	measure = webClient.NewInstance().DoMeasure(context.Background(), false)
 
 	if !measure.IsFailure {
 		t.Errorf("Request to server should have failed")
 	}
 
 }
+
+func TestRequestBody(t *testing.T) {
+	ts := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost || string(body) != `{"query":"ping"}` || r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+	defer ts.Close()
+
+	config := &Config{
+		Target:          ts.URL,
+		ConnTarget:      ts.Listener.Addr().String(),
+		Method:          http.MethodPost,
+		Body:            []byte(`{"query":"ping"}`),
+		BodyContentType: "application/json",
+	}
+	webClient, _ := NewWebClientBuilder(config, &RuntimeConfig{}, nil)
+	client := webClient.NewInstance()
+
+	// the body has to be sent again with each request
+	for i := 0; i < 2; i++ {
+		if measure := client.DoMeasure(context.Background(), false); measure.IsFailure || measure.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: the body was not received by the server (code %d)", i, measure.StatusCode)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("UNIX sockets not supported:", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "sidecar" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	webClient, _ := NewWebClientBuilder(&Config{Target: "http://sidecar/health", UnixSocket: path}, &RuntimeConfig{}, nil)
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+
+	if measure.IsFailure || measure.StatusCode != http.StatusOK {
+		t.Fatalf("request over the UNIX socket should have succeeded: %s", measure.FailureCause)
+	}
+	if measure.MeasuresCollection.Get(stats.DNS).IsValid() || !measure.MeasuresCollection.Get(stats.TCP).IsValid() {
+		t.Error("the connection to the socket should be measured, without DNS resolution")
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	remoteAddrs := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddrs <- r.RemoteAddr
+	}))
+	defer ts.Close()
+
+	config := &Config{Target: ts.URL, ConnTarget: ts.Listener.Addr().String(), SourceAddress: "127.0.0.2"}
+	webClient, _ := NewWebClientBuilder(config, &RuntimeConfig{}, nil)
+	measure := webClient.NewInstance().DoMeasure(context.Background(), false)
+
+	if measure.IsFailure {
+		t.Skip("127.0.0.2 not available as source address:", measure.FailureCause)
+	}
+	if host, _, _ := net.SplitHostPort(<-remoteAddrs); host != "127.0.0.2" {
+		t.Errorf("request should have been sent from 127.0.0.2, not from %s", host)
+	}
+}
@@ -49,6 +49,11 @@ func NewWebClientBuilder(config *Config, runtimeConfig *RuntimeConfig, logger Co
 	}
 	webClient.url = parsedURL
 
+	// certificates are checked once here, so that instances can be built without failing
+	if _, err := newTLSConfig(config, runtimeConfig); err != nil {
+		return nil, err
+	}
+
 	webClient.updateConnTarget()
 
 	return &webClient, nil
@@ -77,7 +82,7 @@ func (webClientBuilder *webClientBuilderImpl) NewInstance() WebClient {
 
 func (webClientBuilder *webClientBuilderImpl) updateConnTarget() {
 	if webClientBuilder.config.ConnTarget == "" {
-		webClientBuilder.resolver = newResolver(webClientBuilder.config)
+		webClientBuilder.resolver = newResolver(webClientBuilder.config, webClientBuilder.runtimeConfig)
 
 		webClientBuilder.connTarget = webClientBuilder.url.Hostname()
 		ipAddr := webClientBuilder.url.Hostname()
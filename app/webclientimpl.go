@@ -17,6 +17,7 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fever.ch/http-ping/net/sockettrace"
@@ -47,9 +48,10 @@ type webClientImpl struct {
 }
 
 func (webClient *webClientImpl) updateConnTarget() {
-	if webClient.config.ConnTarget == "" {
-		webClient.resolver = newResolver(webClient.config)
+	// the resolver is also needed with a forced connection target, to resolve proxies
+	webClient.resolver = newResolver(webClient.config, webClient.runtimeConfig)
 
+	if webClient.config.ConnTarget == "" {
 		webClient.connTarget = webClient.url.Hostname()
 		ipAddr := webClient.url.Hostname()
 
@@ -79,7 +81,7 @@ func newHTTP2RoundTripper(config *Config, runtimeConfig *RuntimeConfig, w *webCl
 
 	webClient.updateConnTarget()
 
-	dialer := &net.Dialer{}
+	dialer := newDialer(config)
 
 	startDNSHook := func(ctx context.Context) {
 		trace := httptrace.ContextClientTrace(ctx)
@@ -96,23 +98,58 @@ func newHTTP2RoundTripper(config *Config, runtimeConfig *RuntimeConfig, w *webCl
 	}
 
 	dialCtx := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		var ipaddr string
+		if webClient.config.UnixSocket != "" {
+			// nothing to resolve, the socket connection is reported as the TCP handshake
+			return sockettrace.NewSocketTrace(ctx, dialer, "unix", webClient.config.UnixSocket)
+		}
+
+		var ipaddr, dnsServer string
 
 		startDNSHook(ctx)
 
-		if webClient.config.ConnTarget == "" {
-			resolvedIpaddr, err := webClient.resolver.resolveConn(webClient.connTarget)
+		measureContext := measureContextFrom(ctx)
+		if measureContext != nil && measureContext.proxy != nil {
+			// the connection is made to the proxy, which resolves the target itself
+			resolvedIpaddr, server, err := webClient.resolver.resolveConn(addr)
 
 			if err != nil {
 				return nil, err
 			}
-			ipaddr = resolvedIpaddr
+			ipaddr, dnsServer = resolvedIpaddr, server
+		} else if webClient.config.ConnTarget == "" {
+			resolvedIpaddr, server, err := webClient.resolver.resolveConn(webClient.connTarget)
+
+			if err != nil {
+				return nil, err
+			}
+			ipaddr, dnsServer = resolvedIpaddr, server
 		} else {
 			ipaddr = webClient.config.ConnTarget
 		}
+		if measureContext != nil {
+			measureContext.dnsServer = dnsServer
+		}
 		stopDNSHook(ctx)
 
-		return sockettrace.NewSocketTrace(ctx, dialer, network, ipaddr)
+		conn, err := sockettrace.NewSocketTrace(ctx, dialer, network, ipaddr)
+		if err != nil {
+			return nil, err
+		}
+		if err = socketOptions(config).Configure(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	tlsConfig, err := newTLSConfig(config, runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxyFunc(config)
+	if err != nil {
+		return nil, err
 	}
 
 	var tlsNextProto map[string]func(string, *tls.Conn) http.RoundTripper
@@ -122,12 +159,11 @@ func newHTTP2RoundTripper(config *Config, runtimeConfig *RuntimeConfig, w *webCl
 	}
 
 	return &http.Transport{
-		Proxy:       http.ProxyFromEnvironment,
-		DialContext: dialCtx,
+		Proxy:                  proxy,
+		OnProxyConnectResponse: onProxyConnectResponse,
+		DialContext:            dialCtx,
 
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.NoCheckCertificate,
-		},
+		TLSClientConfig:    tlsConfig,
 		DisableCompression: config.DisableCompression,
 		ForceAttemptHTTP2:  !webClient.config.HTTP1,
 		MaxIdleConns:       10,
@@ -168,7 +204,10 @@ func (webClient *webClientImpl) update(config *Config, runtimeConfig *RuntimeCon
 
 	webClient.updateConnTarget()
 
-	tr, _ := newTransport(config, runtimeConfig, webClient)
+	tr, err := newTransport(config, runtimeConfig, webClient)
+	if err != nil {
+		return err
+	}
 
 	webClient.httpClient = &http.Client{
 		Timeout:   webClient.config.Wait,
@@ -215,6 +254,9 @@ func (webClient *webClientImpl) prepareReq(req *http.Request) {
 	}
 
 	req.Header.Set("User-Agent", webClient.config.UserAgent)
+	if webClient.config.BodyContentType != "" {
+		req.Header.Set("Content-Type", webClient.config.BodyContentType)
+	}
 	if webClient.config.Referrer != "" {
 		req.Header.Set("Referer", webClient.config.Referrer)
 	}
@@ -234,8 +276,8 @@ func (webClient *webClientImpl) prepareReq(req *http.Request) {
 
 }
 
-// DoMeasure evaluates the latency to a specific HTTP/S server
-func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
+// DoMeasure evaluates the latency to a specific HTTP/S server, the request is aborted if ctx is done in the meantime
+func (webClient *webClientImpl) DoMeasure(ctx context.Context, followRedirect bool) *HTTPMeasure {
 	measureContext := newMeasureContext(webClient)
 	if followRedirect {
 		webClient.httpClient.CheckRedirect = webClient.checkRedirectFollow
@@ -245,11 +287,17 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		}
 	}
 
-	req, _ := http.NewRequest(webClient.config.Method, webClient.config.Target, nil)
+	// a fresh reader for each request, it is also used by net/http to send the body again when following redirects
+	var body io.Reader
+	if webClient.config.Body != nil {
+		body = bytes.NewReader(webClient.config.Body)
+	}
+
+	req, _ := http.NewRequest(webClient.config.Method, webClient.config.Target, body)
 
 	webClient.updateCookieJar()
 
-	req = req.WithContext(measureContext.ctx())
+	req = req.WithContext(measureContext.ctx(ctx))
 
 	webClient.prepareReq(req)
 
@@ -258,6 +306,9 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 	res, err := webClient.httpClient.Do(req)
 
 	if err != nil {
+		if ctx.Err() != nil {
+			return newCancelledMeasure(measureContext)
+		}
 		return &HTTPMeasure{
 			IsFailure:          true,
 			FailureCause:       err.Error(),
@@ -267,17 +318,29 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 
 	altSvcH3 := checkAltSvcH3Header(res.Header)
 
-	if !strings.HasPrefix(req.RequestURI, "http://") && altSvcH3 != nil && !strings.HasPrefix(res.Proto, "HTTP/3") && !webClient.config.HTTP1 && !webClient.config.HTTP2 {
+	if !strings.HasPrefix(req.RequestURI, "http://") && altSvcH3 != nil && measureContext.proxy == nil && webClient.config.UnixSocket == "" && !strings.HasPrefix(res.Proto, "HTTP/3") && !webClient.config.HTTP1 && !webClient.config.HTTP2 {
 
 		webClient.logger.Printf("   ─→     server advertised HTTP/3 endpoint, using HTTP/3\n")
 
-		return webClient.moveToHTTP3(*altSvcH3, measureContext.timerRegistry, followRedirect)
+		return webClient.moveToHTTP3(ctx, *altSvcH3, measureContext.timerRegistry, followRedirect)
 	}
 
 	measureContext.startIngestion()
 
-	s, err := io.Copy(io.Discard, res.Body)
+	// the body is only kept when it has to be checked, and up to a limit
+	var capture *cappedBuffer
+	var sink io.Writer = io.Discard
+	if webClient.config.needsBody() {
+		capture = &cappedBuffer{limit: maxCapturedBody}
+		sink = capture
+	}
+
+	s, err := io.Copy(sink, res.Body)
 	if err != nil {
+		_ = res.Body.Close()
+		if ctx.Err() != nil {
+			return newCancelledMeasure(measureContext)
+		}
 		return &HTTPMeasure{
 			IsFailure:          true,
 			FailureCause:       "I/O error while reading payload",
@@ -296,11 +359,24 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 	failed := false
 	failureCause := ""
 
-	if res.StatusCode/100 == 5 && !webClient.config.IgnoreServerErrors {
+	// expected status codes supersede the handling of server errors
+	if res.StatusCode/100 == 5 && !webClient.config.IgnoreServerErrors && len(webClient.config.ExpectStatus) == 0 {
 		failed = true
 		failureCause = "Server-side error"
 	}
 
+	if !failed {
+		var captured []byte
+		truncated := false
+		if capture != nil {
+			captured, truncated = capture.data, capture.truncated
+		}
+		if cause := checkExpectations(webClient.config, res, captured, truncated); cause != "" {
+			failed = true
+			failureCause = cause
+		}
+	}
+
 	if strings.HasPrefix(res.Proto, "HTTP/1.") && webClient.config.HTTP2 {
 		failed = true
 		failureCause = "HTTP/2 not supported by server"
@@ -311,6 +387,22 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 
 	tlsVersion := extractTLSVersion(res)
 
+	// the handshake is only traced on new connections
+	tlsState := measureContext.tlsState
+	if tlsState == nil {
+		tlsState = res.TLS
+	}
+
+	tlsResumed := measureContext.tlsState != nil && measureContext.tlsState.DidResume
+	used0RTT := false
+	if measureContext.quicConn != nil {
+		// the state of a QUIC connection is only complete once its handshake is done
+		state := measureContext.quicConn.ConnectionState()
+		tlsResumed, used0RTT = state.TLS.DidResume, state.Used0RTT
+	}
+
+	measureContext.updateTCPInfo()
+
 	var remoteAddr = measureContext.remoteAddr
 	if remoteAddr == "" {
 		remoteAddr = webClient.runtimeConfig.ResolvedConnAddress
@@ -326,7 +418,12 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 		Compressed:   res.Uncompressed,
 		TLSEnabled:   res.TLS != nil,
 		TLSVersion:   tlsVersion,
+		TLSState:     tlsState,
+		TLSResumed:   tlsResumed,
+		Used0RTT:     used0RTT,
 		AltSvcH3:     altSvcH3,
+		TCPInfo:      measureContext.tcpInfo,
+		DNSServer:    measureContext.dnsServer,
 
 		MeasuresCollection: measureContext.getMeasures(),
 
@@ -339,6 +436,16 @@ func (webClient *webClientImpl) DoMeasure(followRedirect bool) *HTTPMeasure {
 
 }
 
+// newCancelledMeasure is the outcome of a request aborted because the run was stopped
+func newCancelledMeasure(measureContext *measureContext) *HTTPMeasure {
+	return &HTTPMeasure{
+		IsFailure:          true,
+		Cancelled:          true,
+		FailureCause:       "Cancelled",
+		MeasuresCollection: measureContext.getMeasures(),
+	}
+}
+
 func extractTLSVersion(res *http.Response) string {
 
 	if res.TLS != nil {
@@ -353,7 +460,7 @@ func extractTLSVersion(res *http.Response) string {
 
 }
 
-func (webClient *webClientImpl) moveToHTTP3(altSvcH3 string, timerRegistry *stats.TimerRegistry, followRedirect bool) *HTTPMeasure {
+func (webClient *webClientImpl) moveToHTTP3(ctx context.Context, altSvcH3 string, timerRegistry *stats.TimerRegistry, followRedirect bool) *HTTPMeasure {
 
 	if strings.HasPrefix(altSvcH3, ":") {
 		webClient.url.Host = webClient.url.Host + altSvcH3
@@ -372,7 +479,7 @@ func (webClient *webClientImpl) moveToHTTP3(altSvcH3 string, timerRegistry *stat
 			MeasuresCollection: timerRegistry.Measure(),
 		}
 	}
-	return webClient.DoMeasure(followRedirect)
+	return webClient.DoMeasure(ctx, followRedirect)
 }
 
 func (webClient *webClientImpl) updateCookieJar() {
@@ -18,25 +18,37 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fever.ch/http-ping/app"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"math"
+	"mime/multipart"
 	"net"
 	"net/http"
-	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	rootCmd := prepareRootCmd(app.NewHTTPPing)
-	cobra.CheckErr(rootCmd.Execute())
+	err := rootCmd.Execute()
+
+	// breached thresholds are already reported, they are distinguished from other errors by the exit code
+	var thresholdError *app.ThresholdError
+	if errors.As(err, &thresholdError) {
+		os.Exit(2)
+	}
+	cobra.CheckErr(err)
 }
 
 type stringArrayValue []string
@@ -68,6 +80,32 @@ type extraConfig struct {
 	headers stringArrayValue
 
 	parameters stringArrayValue
+
+	targetsFile string
+
+	rate string
+
+	maxLoss string
+
+	data, dataFile, dataBinary string
+
+	form stringArrayValue
+
+	expectBodyRegex string
+
+	expectHeaders stringArrayValue
+
+	expectJSONPaths stringArrayValue
+
+	certExpiryWarning string
+
+	tlsMin, tlsMax string
+
+	ciphers, curves []string
+
+	dscp int
+
+	tcpNoDelay bool
 }
 
 type runner struct {
@@ -84,7 +122,15 @@ func (runner *runner) run() error {
 		runner.loadTarget,
 		runner.loadLog,
 		runner.loadNetwork,
+		runner.loadSource,
+		runner.loadSocketOptions,
 		runner.loadDNS,
+		runner.loadProxy,
+		runner.loadUnixSocket,
+		runner.loadAllAddresses,
+		runner.loadTLS,
+		runner.loadBody,
+		runner.loadExpectations,
 		runner.loadRest,
 	}
 
@@ -112,24 +158,51 @@ func (runner *runner) isFlagUsed(name string) bool {
 }
 
 func (runner *runner) loadTarget() error {
-	if len(runner.args) == 0 {
+	targets := append([]string{}, runner.args...)
+
+	if runner.xp.targetsFile != "" {
+		fileTargets, err := readTargetsFile(runner.xp.targetsFile)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, fileTargets...)
+	}
+
+	if len(targets) == 0 {
 		_ = runner.cmd.Usage()
 		runner.cmd.Println()
 		return errors.New("target-URL required")
-	} else if len(runner.args) > 1 {
-		_ = runner.cmd.Usage()
-		runner.cmd.Println()
-		return errors.New("too many arguments")
 	}
 
-	runner.config.Target = runner.args[0]
-
-	if a, e := regexp.MatchString("^https?://", runner.config.Target); e == nil && !a {
-		runner.config.Target = "https://" + runner.config.Target
+	for i, target := range targets {
+		if a, e := regexp.MatchString("^https?://", target); e == nil && !a {
+			targets[i] = "https://" + target
+		}
 	}
+
+	runner.config.Target = targets[0]
+	runner.config.Targets = targets
+
 	return nil
 }
 
+// readTargetsFile reads a list of targets, one per line, empty lines and lines starting with # are ignored
+func readTargetsFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("targets file: %s", err)
+	}
+
+	var targets []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			targets = append(targets, line)
+		}
+	}
+	return targets, nil
+}
+
 func (runner *runner) loadNetwork() error {
 	if runner.xp.ipv4 {
 		if runner.xp.ipv6 {
@@ -145,6 +218,39 @@ func (runner *runner) loadNetwork() error {
 	return nil
 }
 
+// loadSource checks the source address and the interface, the IP version of the source address is enforced
+func (runner *runner) loadSource() error {
+	if runner.config.UnixSocket != "" && (runner.config.SourceAddress != "" || runner.config.Interface != "") {
+		return errors.New("a source address or an interface cannot be used with a UNIX socket")
+	}
+
+	if runner.config.Interface != "" {
+		if _, err := net.InterfaceByName(runner.config.Interface); err != nil {
+			return fmt.Errorf("invalid interface `%s'", runner.config.Interface)
+		}
+	}
+
+	if runner.config.SourceAddress == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(runner.config.SourceAddress)
+	if ip == nil {
+		return fmt.Errorf("invalid source address `%s', should be an IPv4 or IPv6 address", runner.config.SourceAddress)
+	}
+
+	ipProtocol := "ip6"
+	if ip.To4() != nil {
+		ipProtocol = "ip4"
+	}
+	if runner.config.IPProtocol != "ip" && runner.config.IPProtocol != ipProtocol {
+		return fmt.Errorf("source address `%s' does not match the IP version enforced", runner.config.SourceAddress)
+	}
+	runner.config.IPProtocol = ipProtocol
+
+	return nil
+}
+
 func (runner *runner) loadLog() error {
 	if runner.xp.verbose {
 		if runner.xp.quiet {
@@ -156,6 +262,10 @@ func (runner *runner) loadLog() error {
 	} else {
 		runner.config.LogLevel = 1
 	}
+
+	if runner.config.OutputFormat != app.OutputText && runner.config.OutputFormat != app.OutputJSONL {
+		return fmt.Errorf("invalid output format `%s', should be %s or %s", runner.config.OutputFormat, app.OutputText, app.OutputJSONL)
+	}
 	return nil
 }
 
@@ -165,15 +275,204 @@ func (runner *runner) loadDNS() error {
 		return errors.New("DNS server cannot specified when full DNS resolutions is enabled")
 	}
 
-	if runner.config.DNSServer == "" || net.ParseIP(runner.config.DNSServer) != nil {
+	switch strings.ToUpper(runner.config.DoHMethod) {
+	case http.MethodGet, http.MethodPost:
+		runner.config.DoHMethod = strings.ToUpper(runner.config.DoHMethod)
+	default:
+		return fmt.Errorf("invalid DoH method `%s', should be GET or POST", runner.config.DoHMethod)
+	}
+
+	if runner.config.DNSMinTTL < 0 || runner.config.DNSMaxTTL < 0 {
+		return errors.New("DNS TTL limits cannot be negative")
+	}
+	if (runner.config.DNSMinTTL > 0 || runner.config.DNSMaxTTL > 0) && !runner.config.CacheDNSRequests {
+		return errors.New("DNS TTL limits require the DNS cache (--dns-cache)")
+	}
+	if runner.config.DNSMaxTTL > 0 && runner.config.DNSMinTTL > runner.config.DNSMaxTTL {
+		return errors.New("the minimum DNS TTL cannot exceed the maximum one")
+	}
+
+	_, err := app.NewBackendResolver(runner.config)
+	return err
+}
+
+func (runner *runner) loadProxy() error {
+	if runner.config.Proxy == "" {
+		return nil
+	}
+
+	if runner.config.NoProxy {
+		return errors.New("proxy and no-proxy cannot be used simultaneously")
+	}
+	if runner.config.HTTP3 || runner.config.CompareProtocols {
+		return errors.New("HTTP/3 cannot be used through a proxy")
+	}
+	if runner.config.ConnTarget != "" {
+		return errors.New("a connection target cannot be forced through a proxy")
+	}
+
+	_, err := app.ParseProxy(runner.config.Proxy)
+	return err
+}
+
+func (runner *runner) loadAllAddresses() error {
+	if !runner.config.AllAddresses {
 		return nil
 	}
 
-	_, err := url.Parse(runner.config.DNSServer)
-	if err == nil {
+	if runner.config.ConnTarget != "" || runner.config.Proxy != "" || runner.config.UnixSocket != "" {
+		return errors.New("all the addresses cannot be pinged with a connection target, a proxy or a UNIX socket")
+	}
+	if runner.config.CompareProtocols || len(runner.config.Targets) > 1 {
+		return errors.New("all the addresses can only be pinged on a single target, with a single protocol")
+	}
+	if runner.config.FullDNS {
+		return errors.New("all the addresses cannot be pinged with a full DNS resolution, which only returns one of them")
+	}
+	if runner.config.TestVersion {
+		return errors.New("all the addresses cannot be pinged while detecting the HTTP versions")
+	}
+	return nil
+}
+
+func (runner *runner) loadUnixSocket() error {
+	if runner.config.UnixSocket == "" {
+		return nil
+	}
+
+	if runner.config.HTTP3 || runner.config.CompareProtocols {
+		return errors.New("HTTP/3 cannot be used over a UNIX socket")
+	}
+	if runner.config.ConnTarget != "" || runner.config.Proxy != "" {
+		return errors.New("a UNIX socket cannot be used with a connection target or a proxy")
+	}
+	return nil
+}
+
+// loadBody builds the body sent with each request, like curl: --data strips the newlines of a file, --data-binary
+// sends it as is, and --form builds a multipart form
+func (runner *runner) loadBody() error {
+	used := 0
+	for _, name := range []string{"data", "data-file", "data-binary", "form"} {
+		if runner.isFlagUsed(name) {
+			used++
+		}
+	}
+	if used == 0 {
 		return nil
 	}
-	return errors.New("DNS server should be an IPv4 address, IPv6 address, or an URL")
+	if used > 1 {
+		return errors.New("data, data-file, data-binary and form cannot be used simultaneously")
+	}
+	if runner.xp.head {
+		return errors.New("a body cannot be sent with HEAD requests")
+	}
+
+	var err error
+	switch {
+	case runner.isFlagUsed("data"):
+		runner.config.Body, err = readData(runner.xp.data)
+		if err == nil && strings.HasPrefix(runner.xp.data, "@") {
+			runner.config.Body = bytes.ReplaceAll(bytes.ReplaceAll(runner.config.Body, []byte("\r"), nil), []byte("\n"), nil)
+		}
+		runner.config.BodyContentType = "application/x-www-form-urlencoded"
+	case runner.isFlagUsed("data-file"):
+		runner.config.Body, err = os.ReadFile(runner.xp.dataFile)
+		runner.config.BodyContentType = "application/octet-stream"
+	case runner.isFlagUsed("data-binary"):
+		runner.config.Body, err = readData(runner.xp.dataBinary)
+		runner.config.BodyContentType = "application/octet-stream"
+	default:
+		runner.config.Body, runner.config.BodyContentType, err = buildForm(runner.xp.form)
+	}
+	if err != nil {
+		return fmt.Errorf("body: %s", err)
+	}
+
+	if !runner.isFlagUsed("method") {
+		runner.config.Method = http.MethodPost
+	}
+	return nil
+}
+
+// readData returns the data itself, or the content of a file when the data is in the form @path
+func readData(data string) ([]byte, error) {
+	if path, found := strings.CutPrefix(data, "@"); found {
+		return os.ReadFile(path)
+	}
+	return []byte(data), nil
+}
+
+// buildForm builds a multipart form from fields in the form name=value, or name=@path to upload a file
+func buildForm(fields []string) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, field := range fields {
+		name, value, found := strings.Cut(field, "=")
+		if !found || name == "" {
+			return nil, "", fmt.Errorf("form fields should be in the form name=value or name=@path, illegal format: \"%s\"", field)
+		}
+
+		path, isFile := strings.CutPrefix(value, "@")
+		if !isFile {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		part, err := writer.CreateFormFile(name, filepath.Base(path))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+func (runner *runner) loadExpectations() error {
+	for _, code := range runner.config.ExpectStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid expected status code `%d'", code)
+		}
+	}
+
+	for _, header := range runner.xp.expectHeaders {
+		n, v, e := splitPair(header)
+		if e != nil {
+			return fmt.Errorf("expected header: %s", e)
+		}
+
+		runner.config.ExpectHeaders = append(runner.config.ExpectHeaders, app.Header{Name: n, Value: strings.TrimSpace(v)})
+	}
+
+	if runner.xp.expectBodyRegex != "" {
+		r, err := regexp.Compile(runner.xp.expectBodyRegex)
+		if err != nil {
+			return fmt.Errorf("expected body: %s", err)
+		}
+		runner.config.ExpectBodyRegex = r
+	}
+
+	for _, expression := range runner.xp.expectJSONPaths {
+		e, err := app.ParseJSONExpectation(expression)
+		if err != nil {
+			return fmt.Errorf("expected JSON: %s", err)
+		}
+		runner.config.ExpectJSON = append(runner.config.ExpectJSON, e)
+	}
+	return nil
 }
 
 func (runner *runner) loadRest() error {
@@ -186,6 +485,31 @@ func (runner *runner) loadRest() error {
 		runner.config.Method = "HEAD"
 	}
 
+	if runner.config.CompareProtocols {
+		if runner.config.HTTP1 || runner.config.HTTP2 || runner.config.HTTP3 {
+			return errors.New("protocols cannot be enforced when comparing protocols")
+		}
+		if len(runner.config.Targets) > 1 {
+			return errors.New("protocols can only be compared on a single target")
+		}
+	}
+
+	if runner.config.ClientKey != "" && runner.config.ClientCert == "" {
+		return errors.New("a key cannot be specified without a client certificate")
+	}
+
+	switch strings.ToUpper(runner.config.ClientCertType) {
+	case "", app.CertTypePEM, app.CertTypeP12:
+	default:
+		return fmt.Errorf("invalid certificate type `%s', should be PEM or P12", runner.config.ClientCertType)
+	}
+
+	certExpiryWarning, err := parseDuration(runner.xp.certExpiryWarning)
+	if err != nil || certExpiryWarning < 0 {
+		return fmt.Errorf("invalid certificate expiry warning `%s'", runner.xp.certExpiryWarning)
+	}
+	runner.config.CertExpiryWarning = certExpiryWarning
+
 	if runner.config.Count <= 0 {
 		return fmt.Errorf("invalid count of requests to be sent `%d'", runner.config.Count)
 	}
@@ -194,6 +518,34 @@ func (runner *runner) loadRest() error {
 		return fmt.Errorf("invalid number of workers `%d'", runner.config.Workers)
 	}
 
+	if runner.xp.maxLoss != "" {
+		maxLoss, err := strconv.ParseFloat(strings.TrimSuffix(runner.xp.maxLoss, "%"), 64)
+		if err != nil || maxLoss < 0 || maxLoss > 100 {
+			return fmt.Errorf("invalid maximal loss `%s'", runner.xp.maxLoss)
+		}
+		maxLoss /= 100
+		runner.config.Thresholds.MaxLoss = &maxLoss
+	}
+
+	if runner.config.Thresholds.MaxAvg < 0 || runner.config.Thresholds.MaxP99 < 0 || runner.config.Thresholds.MinThroughput < 0 {
+		return errors.New("thresholds cannot be negative")
+	}
+
+	if runner.config.Duration < 0 {
+		return fmt.Errorf("invalid duration `%s'", runner.config.Duration)
+	}
+
+	if runner.xp.rate != "" {
+		rate, err := parseRate(runner.xp.rate)
+		if err != nil {
+			return err
+		}
+		if runner.config.MaxWorkers <= 0 {
+			return fmt.Errorf("invalid maximal number of workers `%d'", runner.config.MaxWorkers)
+		}
+		runner.config.Rate = rate
+	}
+
 	for _, cookie := range runner.xp.cookies {
 		n, v, e := splitPair(cookie)
 		if e != nil {
@@ -223,8 +575,42 @@ func (runner *runner) loadRest() error {
 	return nil
 }
 
+// parseRate parses a rate of requests such as "100", "100/s", "30/m" or "5/100ms", and returns it per second
+func parseRate(str string) (float64, error) {
+	count, per, found := strings.Cut(str, "/")
+
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid rate `%s'", str)
+	}
+
+	period := time.Second
+	if found {
+		if per != "" && !unicode.IsDigit(rune(per[0])) {
+			per = "1" + per
+		}
+		if period, err = time.ParseDuration(per); err != nil || period <= 0 {
+			return 0, fmt.Errorf("invalid rate `%s'", str)
+		}
+	}
+
+	return n / period.Seconds(), nil
+}
+
+// parseDuration parses a duration like time.ParseDuration, with days as an additional unit (i.e. "14d")
+func parseDuration(str string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(str, "d"); found {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(str)
+}
+
 func splitPair(str string) (string, string, error) {
-	r := regexp.MustCompile(`^([^:]+):\\s?(.*)$`)
+	r := regexp.MustCompile(`^([^:]+):\s?(.*)$`)
 	e := r.FindStringSubmatch(str)
 	if len(e) == 3 {
 		return e[1], e[2], nil
@@ -249,7 +635,7 @@ func prepareRootCmd(appLogic func(config *app.Config, consoleLogger app.ConsoleL
 		SilenceUsage:  true,
 		SilenceErrors: true,
 
-		Use: "http-ping [flags] target-URL",
+		Use: "http-ping [flags] target-URL [target-URL...]",
 
 		Short: "An utility that evaluates the latency and throuput of HTTP/S requests",
 		Long:  `An utility that evaluates the latency and throuput of HTTP/S requests`,
@@ -262,6 +648,30 @@ func prepareRootCmd(appLogic func(config *app.Config, consoleLogger app.ConsoleL
 
 	rootCmd.Flags().StringVarP(&config.ConnTarget, "conn-target", "", "", "force connection to be done with a specific IP:port (i.e. 127.0.0.1:8080)")
 
+	rootCmd.Flags().StringVarP(&config.SourceAddress, "source-address", "", "", "send the requests from this local IP address")
+
+	rootCmd.Flags().StringVarP(&config.Interface, "interface", "", "", "send the requests through this network interface (Linux only)")
+
+	rootCmd.Flags().IntVarP(&config.TOS, "tos", "", 0, "type of service (IPv4) or traffic class (IPv6) of the packets sent, between 0 and 255 (Linux only)")
+
+	rootCmd.Flags().IntVarP(&xp.dscp, "dscp", "", 0, "DSCP of the packets sent, between 0 and 63, sets the type of service accordingly (Linux only)")
+
+	rootCmd.Flags().IntVarP(&config.SOMark, "so-mark", "", 0, "firewall mark of the packets sent (Linux only)")
+
+	rootCmd.Flags().BoolVarP(&xp.tcpNoDelay, "tcp-nodelay", "", true, "disable the Nagle algorithm of the TCP connections")
+
+	rootCmd.Flags().IntVarP(&config.RcvBuf, "rcvbuf", "", 0, "size of the receive buffer of the sockets, in bytes (Linux only)")
+
+	rootCmd.Flags().IntVarP(&config.SndBuf, "sndbuf", "", 0, "size of the send buffer of the sockets, in bytes (Linux only)")
+
+	rootCmd.Flags().StringVarP(&config.CongestionControl, "congestion-control", "", "", "TCP congestion control algorithm, among the ones available on the host (i.e. cubic or bbr, Linux only)")
+
+	rootCmd.Flags().StringVarP(&config.UnixSocket, "unix-socket", "", "", "send the requests over this UNIX socket, the host of the target-URL is only used in the requests")
+
+	rootCmd.Flags().StringVarP(&config.Proxy, "proxy", "", "", "use this proxy, http://[user:password@]host:port or socks5://[user:password@]host:port (default from the environment)")
+
+	rootCmd.Flags().BoolVarP(&config.NoProxy, "no-proxy", "", false, "do not use any proxy, even if one is defined in the environment")
+
 	rootCmd.Flags().StringVarP(&config.Method, "method", "", http.MethodGet, "select a which HTTP method to be used")
 
 	rootCmd.Flags().BoolVarP(&xp.head, "head", "", false, "perform HTTP HEAD requests instead of GETs")
@@ -286,12 +696,58 @@ func prepareRootCmd(appLogic func(config *app.Config, consoleLogger app.ConsoleL
 
 	rootCmd.Flags().BoolVarP(&config.NoCheckCertificate, "insecure", "k", false, "allow insecure server connections when using SSL")
 
+	rootCmd.Flags().StringVarP(&config.ClientCert, "cert", "E", "", "use a client certificate (PEM, or PKCS#12 for .p12 and .pfx files)")
+
+	rootCmd.Flags().StringVarP(&config.ClientKey, "key", "", "", "private key of the client certificate (PEM), when not included in the certificate file")
+
+	rootCmd.Flags().StringVarP(&config.ClientCertType, "cert-type", "", "", "type of the client certificate, PEM or P12 (guessed from the file extension by default)")
+
+	rootCmd.Flags().StringVarP(&config.ClientCertPassword, "cert-password", "", "", "password of the client certificate (PKCS#12)")
+
+	rootCmd.Flags().StringVarP(&config.CACert, "cacert", "", "", "verify the servers with the CA certificates of this PEM file instead of the system ones")
+
+	rootCmd.Flags().StringVarP(&config.CAPath, "capath", "", "", "verify the servers with the CA certificates (PEM) of this directory instead of the system ones")
+
+	rootCmd.Flags().StringVarP(&config.TLSServerName, "sni", "", "", "send this server name (SNI) in the TLS handshakes, and verify the certificates against it")
+
+	rootCmd.Flags().StringVarP(&xp.tlsMin, "tls-min", "", "", "minimal TLS version to be negotiated (1.0, 1.1, 1.2 or 1.3)")
+
+	rootCmd.Flags().StringVarP(&xp.tlsMax, "tls-max", "", "", "maximal TLS version to be negotiated (1.0, 1.1, 1.2 or 1.3)")
+
+	rootCmd.Flags().StringSliceVarP(&xp.ciphers, "ciphers", "", nil, "restrict the cipher suites offered up to TLS 1.2, by their IANA names (i.e. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)")
+
+	rootCmd.Flags().StringSliceVarP(&xp.curves, "curves", "", nil, "restrict the curves offered for the key exchange, by order of preference (X25519, P256, P384 or P521)")
+
+	rootCmd.Flags().StringSliceVarP(&config.ALPN, "alpn", "", nil, "protocols offered through ALPN, h2 and http/1.1 are added or removed as required by the HTTP version used")
+
+	rootCmd.Flags().BoolVarP(&config.TLSResumption, "tls-resume", "", false, "resume TLS sessions (and use 0-RTT with HTTP/3) on new connections, and compare full and resumed handshakes")
+
+	rootCmd.Flags().BoolVarP(&config.TLSInfo, "tls-info", "", false, "print the details of the TLS session and of the certificates of the first connection")
+
+	rootCmd.Flags().StringVarP(&xp.certExpiryWarning, "cert-expiry-warn", "", "14d", "warn about the certificates expiring within this duration (i.e. 30d)")
+
 	rootCmd.Flags().VarP(&xp.cookies, "cookie", "", "add one or more cookies, in the form name=value")
 
 	rootCmd.Flags().VarP(&xp.headers, "header", "H", "add one or more header, in the form \"name: value\"")
 
 	rootCmd.Flags().VarP(&xp.parameters, "parameter", "", "add one or more parameters to the query, in the form name:value")
 
+	rootCmd.Flags().StringVarP(&xp.data, "data", "", "", "send data in the body of the requests (POST unless a method is specified), @path to read it from a file without its newlines")
+
+	rootCmd.Flags().StringVarP(&xp.dataFile, "data-file", "", "", "send the content of a file in the body of the requests")
+
+	rootCmd.Flags().StringVarP(&xp.dataBinary, "data-binary", "", "", "send data in the body of the requests as is, @path to read it from a file")
+
+	rootCmd.Flags().VarP(&xp.form, "form", "", "add one or more fields to a multipart form sent in the body of the requests, in the form name=value or name=@path")
+
+	rootCmd.Flags().IntSliceVarP(&config.ExpectStatus, "expect-status", "", nil, "handle answers with other status codes as \"lost pings\" (i.e. 200,204)")
+
+	rootCmd.Flags().VarP(&xp.expectHeaders, "expect-header", "", "handle answers without one or more headers as \"lost pings\", in the form \"name: value\" where value has to be contained in the header")
+
+	rootCmd.Flags().StringVarP(&xp.expectBodyRegex, "expect-body-regex", "", "", "handle answers whose body does not match a regular expression as \"lost pings\"")
+
+	rootCmd.Flags().VarP(&xp.expectJSONPaths, "expect-json-path", "", "handle answers whose JSON body does not meet one or more expectations as \"lost pings\" (i.e. '$.status == \"ok\"')")
+
 	rootCmd.Flags().BoolVarP(&config.IgnoreServerErrors, "no-server-error", "", false, "ignore server errors (5xx), do not handle them as \"lost pings\"")
 
 	rootCmd.Flags().BoolVarP(&config.ExtraParam, "extra-parameter", "x", false, "extra changing parameter, add an extra changing parameter to the request to avoid being cached by reverse proxy")
@@ -314,9 +770,15 @@ func prepareRootCmd(appLogic func(config *app.Config, consoleLogger app.ConsoleL
 
 	rootCmd.Flags().BoolVarP(&config.FullDNS, "dns-full-resolution", "D", false, "enable full DNS resolution from the root servers")
 
-	rootCmd.Flags().StringVarP(&config.DNSServer, "dns-server", "d", "", "specify an alternate DNS server for resolutions (URL for DoH)")
+	rootCmd.Flags().StringVarP(&config.DNSServer, "dns-server", "d", "", "specify an alternate DNS server for resolutions (https://, tls:// or quic:// URL for DoH, DoT or DoQ)")
+
+	rootCmd.Flags().StringVarP(&config.DoHMethod, "doh-method", "", http.MethodGet, "HTTP method of the DNS-over-HTTPS queries, GET or POST")
+
+	rootCmd.Flags().BoolVarP(&config.CacheDNSRequests, "dns-cache", "", false, "cache DNS requests, for the TTL of the answers")
+
+	rootCmd.Flags().DurationVarP(&config.DNSMinTTL, "dns-min-ttl", "", 0, "minimum time DNS answers are cached (i.e. 30s)")
 
-	rootCmd.Flags().BoolVarP(&config.CacheDNSRequests, "dns-cache", "", false, "cache DNS requests")
+	rootCmd.Flags().DurationVarP(&config.DNSMaxTTL, "dns-max-ttl", "", 0, "maximum time DNS answers are cached (i.e. 5m, default no limit)")
 
 	rootCmd.Flags().BoolVarP(&config.KeepCookies, "keep-cookies", "", false, "keep received cookies between requests")
 
@@ -324,11 +786,37 @@ func prepareRootCmd(appLogic func(config *app.Config, consoleLogger app.ConsoleL
 
 	rootCmd.Flags().IntVarP(&config.Workers, "workers", "", 1, "define the number of workers to be used")
 
+	rootCmd.Flags().DurationVarP(&config.Duration, "duration", "", 0, "stop after this duration, in-flight requests are then cancelled (i.e. 5m, default no limit)")
+
+	rootCmd.Flags().StringVarP(&xp.rate, "rate", "", "", "send requests at a fixed rate regardless of the response times (i.e. 100/s), count is then the total number of requests")
+
+	rootCmd.Flags().IntVarP(&config.MaxWorkers, "max-workers", "", 256, "define the maximal number of workers used to sustain the rate")
+
 	rootCmd.Flags().BoolVarP(&config.Throughput, "throughput", "t", false, "log the number of requests done per second")
 
 	rootCmd.Flags().DurationVarP(&config.ThroughputRefresh, "throughput-refresh", "T", 5*time.Second, "sampling time for measuring throughput")
 
 	rootCmd.Flags().BoolVarP(&config.TestVersion, "detect-versions", "", false, "detect HTTP protocol versions available on target")
 
+	rootCmd.Flags().BoolVarP(&config.AllAddresses, "all-addresses", "", false, "ping each address the target resolves to concurrently and compare them")
+
+	rootCmd.Flags().BoolVarP(&config.CompareProtocols, "compare-protocols", "", false, "ping the target with HTTP/1.1, HTTP/2 and HTTP/3 concurrently and compare them")
+
+	rootCmd.Flags().StringVarP(&xp.maxLoss, "max-loss", "", "", "fail (exit code 2) if the loss exceeds this percentage (i.e. 1%)")
+
+	rootCmd.Flags().DurationVarP(&config.Thresholds.MaxAvg, "max-avg", "", 0, "fail (exit code 2) if the average latency exceeds this duration (i.e. 100ms)")
+
+	rootCmd.Flags().DurationVarP(&config.Thresholds.MaxP99, "max-p99", "", 0, "fail (exit code 2) if the 99th percentile of the latency exceeds this duration (i.e. 300ms)")
+
+	rootCmd.Flags().Float64VarP(&config.Thresholds.MinThroughput, "min-throughput", "", 0, "fail (exit code 2) if the throughput is lower than this number of queries/sec")
+
+	rootCmd.Flags().StringVarP(&config.OutputFormat, "output", "o", app.OutputText, "select the output format, text or jsonl (one JSON object per measure)")
+
+	rootCmd.Flags().BoolVarP(&config.Histogram, "histogram", "", false, "include a histogram of the latencies in the statistics")
+
+	rootCmd.Flags().StringVarP(&xp.targetsFile, "targets-file", "", "", "read additional target-URLs from a file, one per line")
+
+	rootCmd.Flags().StringVarP(&config.PrometheusListen, "prometheus-listen", "", "", "expose metrics for Prometheus on the /metrics endpoint of this address (i.e. :9115)")
+
 	return rootCmd
 }
@@ -18,9 +18,14 @@ package cmd
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fever.ch/http-ping/app"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 type httpPingMockBuilder struct {
@@ -85,12 +90,21 @@ func TestEnforceIPv4AndIPv6(t *testing.T) {
 
 }
 
-func TestExcessOfArguments(t *testing.T) {
-	_, _, err := commandTest(t, []string{"www.ietf.org", "www.wikipedia.org"})
-	if err == nil {
-		t.Fatal("only one non-flag argument can we used")
+func TestMultipleTargets(t *testing.T) {
+	config, _, err := commandTest(t, []string{"www.ietf.org", "http://www.wikipedia.org"})
+	if err != nil || len(config.Targets) != 2 || config.Targets[0] != "https://www.ietf.org" || config.Targets[1] != "http://www.wikipedia.org" {
+		t.Fatal("multiple targets not taken in account")
 	}
+}
 
+func TestTargetsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets")
+	_ = os.WriteFile(path, []byte("# CDNs\nwww.ietf.org\n\nwww.wikipedia.org\n"), 0o600)
+
+	config, _, err := commandTest(t, []string{"--targets-file", path})
+	if err != nil || len(config.Targets) != 2 || config.Target != "https://www.ietf.org" {
+		t.Fatal("targets file not taken in account")
+	}
 }
 
 func TestLackOfArguments(t *testing.T) {
@@ -106,3 +120,209 @@ func TestCookie(t *testing.T) {
 		t.Fatal("cookie flag not taken in account")
 	}
 }
+
+func TestCompareProtocolsWithEnforcedProtocol(t *testing.T) {
+	_, _, err := commandTest(t, []string{"--compare-protocols", "-3", "www.google.com"})
+	if err == nil {
+		t.Fatal("protocols cannot be compared when one of them is enforced")
+	}
+}
+
+func TestRate(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--rate", "30/m", "www.google.com"})
+	if err != nil || config.Rate != 0.5 {
+		t.Fatal("rate flag not taken in account")
+	}
+
+	_, _, err = commandTest(t, []string{"--rate", "-3/s", "www.google.com"})
+	if err == nil {
+		t.Fatal("negative rate should be rejected")
+	}
+}
+
+func TestMaxLoss(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--max-loss", "2.5%", "www.google.com"})
+	if err != nil || config.Thresholds.MaxLoss == nil || *config.Thresholds.MaxLoss != 0.025 {
+		t.Fatal("max-loss flag not taken in account")
+	}
+}
+
+func TestData(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--data", "a=1&b=2", "www.google.com"})
+	if err != nil || string(config.Body) != "a=1&b=2" || config.Method != "POST" || config.BodyContentType != "application/x-www-form-urlencoded" {
+		t.Fatal("data flag not taken in account")
+	}
+}
+
+func TestForm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.txt")
+	_ = os.WriteFile(path, []byte("payload"), 0o600)
+
+	config, _, err := commandTest(t, []string{"--form", "name=value", "--form", "file=@" + path, "--method", "PUT", "www.google.com"})
+	if err != nil || config.Method != "PUT" || !bytes.Contains(config.Body, []byte("payload")) || !strings.HasPrefix(config.BodyContentType, "multipart/form-data") {
+		t.Fatal("form flag not taken in account")
+	}
+}
+
+func TestDataAndForm(t *testing.T) {
+	_, _, err := commandTest(t, []string{"--data", "a=1", "--form", "b=2", "www.google.com"})
+	if err == nil {
+		t.Fatal("data and form cannot be used simultaneously")
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--expect-status", "200,204", "--expect-json-path", `$.status == "ok"`, "--expect-body-regex", "o+k", "www.google.com"})
+	if err != nil || len(config.ExpectStatus) != 2 || len(config.ExpectJSON) != 1 || config.ExpectBodyRegex == nil {
+		t.Fatal("expectation flags not taken in account")
+	}
+
+	_, _, err = commandTest(t, []string{"--expect-json-path", `status == "ok"`, "www.google.com"})
+	if err == nil {
+		t.Fatal("invalid JSON path should be rejected")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	config, _, err := commandTest(t, []string{"-H", "Content-Type: application/json", "--expect-header", "X-Id: 1", "www.google.com"})
+	if err != nil || len(config.Headers) != 1 || config.Headers[0].Name != "Content-Type" || config.Headers[0].Value != "application/json" {
+		t.Fatal("header flag not taken in account")
+	}
+	if len(config.ExpectHeaders) != 1 || config.ExpectHeaders[0].Name != "X-Id" || config.ExpectHeaders[0].Value != "1" {
+		t.Fatal("expect-header flag not taken in account")
+	}
+}
+
+func TestCertType(t *testing.T) {
+	_, _, err := commandTest(t, []string{"--cert", "client.der", "--cert-type", "DER", "www.google.com"})
+	if err == nil {
+		t.Fatal("unsupported certificate type should be rejected")
+	}
+}
+
+func TestTLSFlags(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--tls-min", "1.2", "--tls-max", "TLSv1.3", "--curves", "X25519,P-256", "--ciphers", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "www.google.com"})
+	if err != nil || config.TLSMinVersion != tls.VersionTLS12 || config.TLSMaxVersion != tls.VersionTLS13 || len(config.Curves) != 2 || len(config.CipherSuites) != 1 {
+		t.Fatal("TLS flags not taken in account")
+	}
+
+	_, _, err = commandTest(t, []string{"--tls-min", "1.3", "--tls-max", "1.2", "www.google.com"})
+	if err == nil {
+		t.Fatal("inconsistent TLS versions should be rejected")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--proxy", "socks5://127.0.0.1:1080", "www.google.com"})
+	if err != nil || config.Proxy != "socks5://127.0.0.1:1080" {
+		t.Fatal("proxy flag not taken in account")
+	}
+
+	for _, args := range [][]string{
+		{"--proxy", "ftp://127.0.0.1:21", "www.google.com"},
+		{"--proxy", "http://127.0.0.1:3128", "--no-proxy", "www.google.com"},
+		{"--proxy", "http://127.0.0.1:3128", "-3", "www.google.com"},
+	} {
+		if _, _, err = commandTest(t, args); err == nil {
+			t.Fatalf("proxy flags %v should be rejected", args)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--unix-socket", "/run/app.sock", "http://localhost/health"})
+	if err != nil || config.UnixSocket != "/run/app.sock" {
+		t.Fatal("unix-socket flag not taken in account")
+	}
+
+	_, _, err = commandTest(t, []string{"--unix-socket", "/run/app.sock", "-3", "http://localhost/health"})
+	if err == nil {
+		t.Fatal("HTTP/3 cannot be used over a UNIX socket")
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--source-address", "127.0.0.1", "www.google.com"})
+	if err != nil || config.SourceAddress != "127.0.0.1" || config.IPProtocol != "ip4" {
+		t.Fatal("source-address flag not taken in account")
+	}
+
+	_, _, err = commandTest(t, []string{"--source-address", "127.0.0.1", "-6", "www.google.com"})
+	if err == nil {
+		t.Fatal("an IPv4 source address cannot be used with IPv6")
+	}
+
+	_, _, err = commandTest(t, []string{"--interface", "does-not-exist0", "www.google.com"})
+	if err == nil {
+		t.Fatal("unknown interfaces should be rejected")
+	}
+}
+
+func TestSocketOptions(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--dscp", "46", "--tcp-nodelay=false", "--congestion-control", "bbr", "www.google.com"})
+	if err != nil || config.TOS != 184 || config.TCPNoDelay == nil || *config.TCPNoDelay || config.CongestionControl != "bbr" {
+		t.Fatal("socket option flags not taken in account")
+	}
+
+	for _, args := range [][]string{
+		{"--dscp", "64", "www.google.com"},
+		{"--dscp", "46", "--tos", "184", "www.google.com"},
+		{"--rcvbuf", "-1", "www.google.com"},
+	} {
+		if _, _, err = commandTest(t, args); err == nil {
+			t.Fatalf("socket options %v should be rejected", args)
+		}
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--dns-server", "https://dns.google/dns-query", "--doh-method", "post", "www.google.com"})
+	if err != nil || config.DoHMethod != "POST" {
+		t.Fatal("DoH flags not taken in account")
+	}
+
+	for _, args := range [][]string{
+		{"--dns-server", "ftp://dns.google", "www.google.com"},
+		{"--dns-server", "tls://", "www.google.com"},
+		{"--dns-server", "https://dns.google/dns-query", "--doh-method", "PUT", "www.google.com"},
+	} {
+		if _, _, err = commandTest(t, args); err == nil {
+			t.Fatalf("DNS flags %v should be rejected", args)
+		}
+	}
+}
+
+func TestDNSCache(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--dns-cache", "--dns-min-ttl", "30s", "--dns-max-ttl", "5m", "www.google.com"})
+	if err != nil || config.DNSMinTTL != 30*time.Second || config.DNSMaxTTL != 5*time.Minute {
+		t.Fatal("DNS cache flags not taken in account")
+	}
+
+	for _, args := range [][]string{
+		{"--dns-min-ttl", "30s", "www.google.com"},
+		{"--dns-cache", "--dns-min-ttl", "5m", "--dns-max-ttl", "30s", "www.google.com"},
+	} {
+		if _, _, err = commandTest(t, args); err == nil {
+			t.Fatalf("DNS cache flags %v should be rejected", args)
+		}
+	}
+}
+
+func TestAllAddresses(t *testing.T) {
+	config, _, err := commandTest(t, []string{"--all-addresses", "www.google.com"})
+	if err != nil || !config.AllAddresses {
+		t.Fatal("all-addresses flag not taken in account")
+	}
+
+	for _, args := range [][]string{
+		{"--all-addresses", "--conn-target", "127.0.0.1:443", "www.google.com"},
+		{"--all-addresses", "--compare-protocols", "www.google.com"},
+		{"--all-addresses", "www.google.com", "www.wikipedia.org"},
+		{"--all-addresses", "--dns-full-resolution", "www.google.com"},
+		{"--all-addresses", "--detect-versions", "www.google.com"},
+	} {
+		if _, _, err = commandTest(t, args); err == nil {
+			t.Fatalf("all-addresses flags %v should be rejected", args)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+func (runner *runner) loadSocketOptions() error {
+	if runner.isFlagUsed("dscp") {
+		if runner.isFlagUsed("tos") {
+			return errors.New("tos and dscp cannot be used simultaneously")
+		}
+		if runner.xp.dscp < 0 || runner.xp.dscp > 63 {
+			return fmt.Errorf("invalid DSCP `%d', should be between 0 and 63", runner.xp.dscp)
+		}
+		// the DSCP is made of the 6 upper bits of the type of service, the 2 lower ones being used by ECN
+		runner.config.TOS = runner.xp.dscp << 2
+	}
+
+	if runner.config.TOS < 0 || runner.config.TOS > 255 {
+		return fmt.Errorf("invalid type of service `%d', should be between 0 and 255", runner.config.TOS)
+	}
+	if runner.config.SOMark < 0 {
+		return fmt.Errorf("invalid mark `%d'", runner.config.SOMark)
+	}
+	if runner.config.RcvBuf < 0 || runner.config.SndBuf < 0 {
+		return errors.New("buffer sizes cannot be negative")
+	}
+
+	if runner.isFlagUsed("tcp-nodelay") {
+		noDelay := runner.xp.tcpNoDelay
+		runner.config.TCPNoDelay = &noDelay
+	}
+
+	if runner.config.UnixSocket != "" && (runner.config.TOS != 0 || runner.config.SOMark != 0 || runner.config.CongestionControl != "") {
+		return errors.New("tos, dscp, so-mark and congestion-control cannot be used with a UNIX socket")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"x25519": tls.X25519,
+	"p256":   tls.CurveP256,
+	"p384":   tls.CurveP384,
+	"p521":   tls.CurveP521,
+}
+
+// parseTLSVersion parses a TLS version such as "1.2", "TLS1.2" or "tlsv1.2"
+func parseTLSVersion(str string) (uint16, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(str)), "tls"), "v")
+	if version, ok := tlsVersions[v]; ok {
+		return version, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version `%s', should be 1.0, 1.1, 1.2 or 1.3", str)
+}
+
+// parseCipherSuites parses cipher suites given by their IANA names (i.e. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), the
+// suites of TLS 1.3 are not configurable
+func parseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]*tls.CipherSuite)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		suite, ok := suites[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite `%s'", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, fmt.Errorf("cipher suite `%s' is a TLS 1.3 one, those cannot be configured", name)
+		}
+		ids = append(ids, suite.ID)
+	}
+	return ids, nil
+}
+
+// parseCurves parses elliptic curves given by their names (X25519, P256, P384 or P521)
+func parseCurves(names []string) ([]tls.CurveID, error) {
+	var curves []tls.CurveID
+	for _, name := range names {
+		curve, ok := tlsCurves[strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "")]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve `%s', should be X25519, P256, P384 or P521", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
+
+func (runner *runner) loadTLS() error {
+	var err error
+
+	if runner.xp.tlsMin != "" {
+		if runner.config.TLSMinVersion, err = parseTLSVersion(runner.xp.tlsMin); err != nil {
+			return err
+		}
+	}
+
+	if runner.xp.tlsMax != "" {
+		if runner.config.TLSMaxVersion, err = parseTLSVersion(runner.xp.tlsMax); err != nil {
+			return err
+		}
+	}
+
+	if runner.config.TLSMinVersion != 0 && runner.config.TLSMaxVersion != 0 && runner.config.TLSMinVersion > runner.config.TLSMaxVersion {
+		return errors.New("minimal TLS version cannot be greater than the maximal one")
+	}
+
+	if runner.config.HTTP3 && runner.config.TLSMaxVersion != 0 && runner.config.TLSMaxVersion < tls.VersionTLS13 {
+		return errors.New("HTTP/3 requires TLS 1.3")
+	}
+
+	if runner.config.CipherSuites, err = parseCipherSuites(runner.xp.ciphers); err != nil {
+		return err
+	}
+
+	if runner.config.Curves, err = parseCurves(runner.xp.curves); err != nil {
+		return err
+	}
+
+	return nil
+}
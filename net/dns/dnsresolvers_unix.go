@@ -22,7 +22,18 @@ import (
 	"github.com/miekg/dns"
 )
 
+// HostsPath is the path of the hosts file of the system
+const HostsPath = "/etc/hosts"
+
 func GetDNSServers() ([]string, error) {
-	config, _ := dns.ClientConfigFromFile("/etc/resolv.conf")
+	config, err := GetClientConfig()
+	if err != nil {
+		return nil, err
+	}
 	return config.Servers, nil
 }
+
+// GetClientConfig returns the configuration of the resolver of the system (servers, search domains and options)
+func GetClientConfig() (*dns.ClientConfig, error) {
+	return dns.ClientConfigFromFile("/etc/resolv.conf")
+}
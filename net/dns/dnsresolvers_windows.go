@@ -21,9 +21,13 @@ package dns
 import (
 	"log"
 
+	"github.com/miekg/dns"
 	wmi "github.com/yusufpapurcu/wmi"
 )
 
+// HostsPath is the path of the hosts file of the system
+const HostsPath = `C:\Windows\System32\drivers\etc\hosts`
+
 type Win32_NetworkAdapterConfiguration struct {
 	DNSServerSearchOrder []string
 }
@@ -44,3 +48,12 @@ func GetDNSServers() ([]string, error) {
 	}
 	return list, nil
 }
+
+// GetClientConfig returns the configuration of the resolver of the system, with the default options
+func GetClientConfig() (*dns.ClientConfig, error) {
+	servers, err := GetDNSServers()
+	if err != nil {
+		return nil, err
+	}
+	return &dns.ClientConfig{Servers: servers, Port: "53", Ndots: 1, Timeout: 5, Attempts: 2}, nil
+}
@@ -119,6 +119,11 @@ func (t *ConnTrace) compose(old *ConnTrace) {
 	}
 }
 
+// NetConn returns the underlying connection
+func (sta *connAdapter) NetConn() net.Conn {
+	return sta.innerConn
+}
+
 // Read behaves is a proxy to the actual conn.Read (counts reads)
 func (sta *connAdapter) Read(b []byte) (int, error) {
 	n, err := sta.innerConn.Read(b)
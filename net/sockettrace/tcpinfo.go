@@ -0,0 +1,46 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"net"
+	"time"
+)
+
+// TCPInfo are the statistics maintained by the kernel for a TCP connection
+type TCPInfo struct {
+	RTT              time.Duration
+	RTTVar           time.Duration
+	Retransmits      uint32 // segments retransmitted since the connection was established
+	CongestionWindow uint32 // in segments
+	MSS              uint32
+}
+
+// GetTCPInfo returns the kernel statistics of the TCP connection underlying conn (possibly a TLS connection or a traced
+// connection), or nil if they are not available, i.e. on other platforms than Linux
+func GetTCPInfo(conn net.Conn) *TCPInfo {
+	for {
+		switch c := conn.(type) {
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		case *net.TCPConn:
+			return tcpInfo(c)
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+	"time"
+)
+
+func tcpInfo(conn *net.TCPConn) *TCPInfo {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var info *unix.TCPInfo
+	var sockErr error
+	if err = rawConn.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || sockErr != nil {
+		return nil
+	}
+
+	return &TCPInfo{
+		RTT:              time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar:           time.Duration(info.Rttvar) * time.Microsecond,
+		Retransmits:      info.Total_retrans,
+		CongestionWindow: info.Snd_cwnd,
+		MSS:              info.Snd_mss,
+	}
+}
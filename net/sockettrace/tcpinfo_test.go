@@ -0,0 +1,60 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockettrace
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestGetTCPInfo(t *testing.T) {
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+
+	ctx := WithTrace(context.Background(), &ConnTrace{})
+	conn, err := NewSocketTrace(ctx, &net.Dialer{}, "tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	defer tlsConn.Close()
+	if err = tlsConn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	info := GetTCPInfo(tlsConn)
+	if runtime.GOOS != "linux" {
+		if info != nil {
+			t.Fatal("TCP statistics are only available on Linux")
+		}
+		return
+	}
+	if info == nil || info.MSS == 0 || info.CongestionWindow == 0 {
+		t.Fatalf("TCP statistics of the connection not available: %+v", info)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if GetTCPInfo(client) != nil {
+		t.Fatal("no TCP statistics are available for other connections")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sockopt applies options to the sockets before they are connected
+package sockopt
+
+import (
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Options are the options applied to the sockets, zero values are left untouched
+type Options struct {
+	// Interface is the name of the network interface the sockets are bound to
+	Interface string
+
+	// TOS is the type of service (IPv4) or traffic class (IPv6) of the packets, the DSCP being its 6 upper bits
+	TOS int
+
+	// Mark is the firewall mark (SO_MARK) of the packets
+	Mark int
+
+	// RcvBuf and SndBuf are the sizes of the receive and send buffers (SO_RCVBUF and SO_SNDBUF)
+	RcvBuf, SndBuf int
+
+	// CongestionControl is the TCP congestion control algorithm (i.e. cubic or bbr)
+	CongestionControl string
+
+	// NoDelay disables (true) or enables (false) the Nagle algorithm of TCP connections, it is disabled by default
+	NoDelay *bool
+}
+
+// IsSet returns true if at least one option has to be applied before the sockets are connected
+func (o *Options) IsSet() bool {
+	return o.Interface != "" || o.TOS != 0 || o.Mark != 0 || o.RcvBuf != 0 || o.SndBuf != 0 || o.CongestionControl != ""
+}
+
+// Control returns a function applying the options, to be used as the Control function of a net.Dialer or of a
+// net.ListenConfig
+func (o *Options) Control() func(network, address string, c syscall.RawConn) error {
+	if !o.IsSet() {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if e := c.Control(func(fd uintptr) {
+			err = o.apply(fd, strings.HasSuffix(network, "6"), strings.HasPrefix(network, "tcp"))
+		}); e != nil {
+			return e
+		}
+		return err
+	}
+}
+
+// Configure applies the options which can only be set once a connection is established, conn can be wrapped by
+// connections providing a NetConn method (i.e. tls.Conn)
+func (o *Options) Configure(conn net.Conn) error {
+	if o.NoDelay == nil {
+		return nil
+	}
+
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c.SetNoDelay(*o.NoDelay)
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
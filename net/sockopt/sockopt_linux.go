@@ -0,0 +1,70 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockopt
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+)
+
+func (o *Options) apply(fd uintptr, ipv6, tcp bool) error {
+	s := int(fd)
+
+	if o.Interface != "" {
+		if err := unix.BindToDevice(s, o.Interface); err != nil {
+			return fmt.Errorf("cannot bind to interface `%s': %s", o.Interface, err)
+		}
+	}
+
+	if o.TOS != 0 {
+		var err error
+		if ipv6 {
+			err = unix.SetsockoptInt(s, unix.IPPROTO_IPV6, unix.IPV6_TCLASS, o.TOS)
+		} else {
+			err = unix.SetsockoptInt(s, unix.IPPROTO_IP, unix.IP_TOS, o.TOS)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot set type of service `%d': %s", o.TOS, err)
+		}
+	}
+
+	if o.Mark != 0 {
+		if err := unix.SetsockoptInt(s, unix.SOL_SOCKET, unix.SO_MARK, o.Mark); err != nil {
+			return fmt.Errorf("cannot set mark `%d': %s", o.Mark, err)
+		}
+	}
+
+	if o.RcvBuf != 0 {
+		if err := unix.SetsockoptInt(s, unix.SOL_SOCKET, unix.SO_RCVBUF, o.RcvBuf); err != nil {
+			return fmt.Errorf("cannot set receive buffer size `%d': %s", o.RcvBuf, err)
+		}
+	}
+
+	if o.SndBuf != 0 {
+		if err := unix.SetsockoptInt(s, unix.SOL_SOCKET, unix.SO_SNDBUF, o.SndBuf); err != nil {
+			return fmt.Errorf("cannot set send buffer size `%d': %s", o.SndBuf, err)
+		}
+	}
+
+	if o.CongestionControl != "" && tcp {
+		if err := unix.SetsockoptString(s, unix.IPPROTO_TCP, unix.TCP_CONGESTION, o.CongestionControl); err != nil {
+			return fmt.Errorf("cannot set congestion control `%s': %s", o.CongestionControl, err)
+		}
+	}
+
+	return nil
+}
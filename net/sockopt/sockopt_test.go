@@ -0,0 +1,76 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sockopt
+
+import (
+	"net"
+	"runtime"
+	"testing"
+)
+
+func TestInterface(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	dialer := net.Dialer{Control: (&Options{Interface: "lo"}).Control()}
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			t.Fatal("binding to an interface should fail outside of Linux")
+		}
+		return
+	}
+	if err != nil {
+		t.Skip("cannot bind to the loopback interface:", err)
+	}
+	_ = conn.Close()
+
+	dialer = net.Dialer{Control: (&Options{Interface: "does-not-exist0"}).Control()}
+	if _, err = dialer.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Fatal("binding to an unknown interface should fail")
+	}
+}
+
+func TestNoDelay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	noDelay := false
+	if err = (&Options{NoDelay: &noDelay}).Configure(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err = (&Options{NoDelay: &noDelay}).Configure(client); err != nil {
+		t.Fatal("options should be ignored on other connections than TCP")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// HistogramRelativeAccuracy is the maximal relative error of the quantiles returned by a Histogram
+const HistogramRelativeAccuracy = 0.01
+
+var (
+	histogramGamma    = (1 + HistogramRelativeAccuracy) / (1 - HistogramRelativeAccuracy)
+	histogramLogGamma = math.Log(histogramGamma)
+)
+
+// Histogram is a mergeable quantile sketch: measures are counted in buckets whose bounds grow geometrically, thus
+// the memory used only depends on the range of the measures (a few thousands buckets at most for durations), not on
+// their number. Min, max, average and standard deviation are computed exactly.
+type Histogram struct {
+	buckets map[int]uint64
+	zeros   uint64
+	count   uint64
+	mean    float64
+	m2      float64
+	min     Measure
+	max     Measure
+}
+
+// HistogramBin is a range of measures and the number of measures observed in it
+type HistogramBin struct {
+	From  Measure
+	To    Measure
+	Count uint64
+}
+
+// NewHistogram builds an empty Histogram
+func NewHistogram() *Histogram {
+	return &Histogram{
+		buckets: make(map[int]uint64),
+		min:     MeasureNotValid,
+		max:     MeasureNotValid,
+	}
+}
+
+func bucketIndex(m Measure) int {
+	return int(math.Ceil(math.Log(float64(m)) / histogramLogGamma))
+}
+
+func bucketValue(index int) float64 {
+	return 2 * math.Pow(histogramGamma, float64(index)) / (histogramGamma + 1)
+}
+
+// Record adds a measure to the histogram, invalid measures are ignored
+func (h *Histogram) Record(m Measure) {
+	if !m.IsValid() {
+		return
+	}
+
+	if m <= 0 {
+		h.zeros++
+	} else {
+		h.buckets[bucketIndex(m)]++
+	}
+
+	if h.count == 0 || m < h.min {
+		h.min = m
+	}
+	if h.count == 0 || m > h.max {
+		h.max = m
+	}
+
+	// Welford's online algorithm, numerically stable even after billions of measures
+	h.count++
+	delta := float64(m) - h.mean
+	h.mean += delta / float64(h.count)
+	h.m2 += delta * (float64(m) - h.mean)
+}
+
+// Merge adds all the measures recorded in other to the histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+
+	for i, c := range other.buckets {
+		h.buckets[i] += c
+	}
+	h.zeros += other.zeros
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if h.count == 0 || other.max > h.max {
+		h.max = other.max
+	}
+
+	count := h.count + other.count
+	delta := other.mean - h.mean
+	h.mean += delta * float64(other.count) / float64(count)
+	h.m2 += other.m2 + delta*delta*float64(h.count)*float64(other.count)/float64(count)
+	h.count = count
+}
+
+// Count returns the number of measures recorded
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Min returns the smallest measure recorded, or an invalid measure if the histogram is empty
+func (h *Histogram) Min() Measure {
+	return h.min
+}
+
+// Max returns the largest measure recorded, or an invalid measure if the histogram is empty
+func (h *Histogram) Max() Measure {
+	return h.max
+}
+
+// Average returns the average of the measures recorded, or an invalid measure if the histogram is empty
+func (h *Histogram) Average() Measure {
+	if h.count == 0 {
+		return MeasureNotValid
+	}
+	return Measure(h.mean)
+}
+
+// StdDev returns the standard deviation of the measures recorded, or an invalid measure if the histogram is empty
+func (h *Histogram) StdDev() Measure {
+	if h.count == 0 {
+		return MeasureNotValid
+	}
+	return Measure(math.Sqrt(h.m2 / float64(h.count)))
+}
+
+func (h *Histogram) sortedIndexes() []int {
+	indexes := make([]int, 0, len(h.buckets))
+	for i := range h.buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (h *Histogram) clamp(v float64) Measure {
+	return Measure(math.Max(float64(h.min), math.Min(float64(h.max), v)))
+}
+
+// Quantile returns an estimation of the q-quantile (0 <= q <= 1) of the measures recorded, the relative error of the
+// estimation is at most HistogramRelativeAccuracy. An invalid measure is returned if the histogram is empty.
+func (h *Histogram) Quantile(q float64) Measure {
+	if h.count == 0 {
+		return MeasureNotValid
+	}
+
+	// nearest-rank method
+	rank := uint64(math.Max(0, math.Ceil(q*float64(h.count))-1))
+
+	if rank < h.zeros {
+		return h.clamp(0)
+	}
+	seen := h.zeros
+
+	for _, i := range h.sortedIndexes() {
+		seen += h.buckets[i]
+		if seen > rank {
+			return h.clamp(bucketValue(i))
+		}
+	}
+	return h.max
+}
+
+// Bins splits the range of the measures recorded into n bins, the bounds of the bins grow geometrically when
+// possible, which is the most readable for latencies
+func (h *Histogram) Bins(n int) []HistogramBin {
+	if h.count == 0 || n <= 0 {
+		return nil
+	}
+	if h.min == h.max {
+		return []HistogramBin{{From: h.min, To: h.max, Count: h.count}}
+	}
+
+	bounds := make([]float64, n+1)
+	for k := 0; k <= n; k++ {
+		if h.min > 0 {
+			bounds[k] = float64(h.min) * math.Pow(float64(h.max)/float64(h.min), float64(k)/float64(n))
+		} else {
+			bounds[k] = float64(h.min) + (float64(h.max)-float64(h.min))*float64(k)/float64(n)
+		}
+	}
+
+	bins := make([]HistogramBin, n)
+	for k := range bins {
+		bins[k] = HistogramBin{From: Measure(bounds[k]), To: Measure(bounds[k+1])}
+	}
+
+	binOf := func(v float64) int {
+		return sort.SearchFloat64s(bounds[1:n], v)
+	}
+
+	if h.zeros > 0 {
+		bins[binOf(float64(h.clamp(0)))].Count += h.zeros
+	}
+	for i, c := range h.buckets {
+		bins[binOf(float64(h.clamp(bucketValue(i))))].Count += c
+	}
+	return bins
+}
+
+// PhaseHistograms keeps a Histogram for each phase of a request, a phase only accounts for the measures in which it
+// is valid (i.e. the handshakes are not accounted when a connection is reused)
+type PhaseHistograms struct {
+	histograms map[TimerType]*Histogram
+}
+
+// NewPhaseHistograms builds an empty PhaseHistograms
+func NewPhaseHistograms() *PhaseHistograms {
+	return &PhaseHistograms{histograms: make(map[TimerType]*Histogram)}
+}
+
+// Record adds the valid measures of a MeasuresCollection to the histograms of their respective phases
+func (ph *PhaseHistograms) Record(mc *MeasuresCollection) {
+	for tt, m := range mc.timers {
+		if !m.IsValid() {
+			continue
+		}
+		if _, ok := ph.histograms[tt]; !ok {
+			ph.histograms[tt] = NewHistogram()
+		}
+		ph.histograms[tt].Record(m)
+	}
+}
+
+// Merge adds all the measures recorded in other to the histograms
+func (ph *PhaseHistograms) Merge(other *PhaseHistograms) {
+	for tt, h := range other.histograms {
+		if _, ok := ph.histograms[tt]; !ok {
+			ph.histograms[tt] = NewHistogram()
+		}
+		ph.histograms[tt].Merge(h)
+	}
+}
+
+// Get returns the Histogram of a phase, or nil if no valid measure was recorded for it
+func (ph *PhaseHistograms) Get(tt TimerType) *Histogram {
+	return ph.histograms[tt]
+}
@@ -0,0 +1,112 @@
+// Copyright 2022-2023 - Raphaël P. Barazzutti
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(Measure(time.Duration(i) * time.Millisecond))
+	}
+
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99, 0.999} {
+		want := q * 9999 * float64(time.Millisecond)
+		got := float64(h.Quantile(q))
+		if math.Abs(got-want)/want > HistogramRelativeAccuracy+0.001 {
+			t.Errorf("quantile %.3f was incorrect, got: %.0f, want: %.0f", q, got, want)
+		}
+	}
+
+	if h.Min() != Measure(time.Millisecond) || h.Max() != Measure(10000*time.Millisecond) || h.Count() != 10000 {
+		t.Errorf("min/max/count were incorrect, got: %d/%d/%d", h.Min(), h.Max(), h.Count())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		m := Measure(time.Duration(i*i) * time.Microsecond)
+		if i%3 == 0 {
+			a.Record(m)
+		} else {
+			b.Record(m)
+		}
+		all.Record(m)
+	}
+
+	a.Merge(b)
+
+	if a.Count() != all.Count() || a.Min() != all.Min() || a.Max() != all.Max() || a.Quantile(0.99) != all.Quantile(0.99) {
+		t.Errorf("merged histogram differs from the histogram of all the measures")
+	}
+	if math.Abs(float64(a.Average()-all.Average())) > 1 || math.Abs(float64(a.StdDev()-all.StdDev())) > 1 {
+		t.Errorf("average/stddev of merged histogram were incorrect, got: %d/%d, want: %d/%d", a.Average(), a.StdDev(), all.Average(), all.StdDev())
+	}
+}
+
+func TestHistogramBoundedMemory(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 1000000; i++ {
+		h.Record(Measure(time.Duration(i%100000) * time.Millisecond))
+	}
+
+	if len(h.buckets) > 2000 {
+		t.Errorf("too many buckets: %d", len(h.buckets))
+	}
+}
+
+func TestHistogramBins(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Record(Measure(time.Duration(i) * time.Millisecond))
+	}
+
+	total := uint64(0)
+	for _, bin := range h.Bins(10) {
+		total += bin.Count
+	}
+	if total != 100 {
+		t.Errorf("bins should contain all the measures, got: %d", total)
+	}
+}
+
+func TestPhaseHistograms(t *testing.T) {
+	ph := NewPhaseHistograms()
+
+	fresh := NewMeasureRegistry()
+	fresh.Set(TCP, Measure(10*time.Millisecond))
+	fresh.Set(Total, Measure(30*time.Millisecond))
+
+	reused := NewMeasureRegistry()
+	reused.Set(TCP, MeasureNotInitialized)
+	reused.Set(Total, Measure(10*time.Millisecond))
+
+	ph.Record(fresh)
+	ph.Record(reused)
+
+	if ph.Get(TCP).Count() != 1 || ph.Get(Total).Count() != 2 || ph.Get(TLS) != nil {
+		t.Errorf("only valid measures should be recorded per phase")
+	}
+	if ph.Get(Total).Average() != Measure(20*time.Millisecond) {
+		t.Errorf("average of total was incorrect, got: %d", ph.Get(Total).Average())
+	}
+}
@@ -27,6 +27,11 @@ type PingStats struct {
 	Min     Measure
 	Max     Measure
 	StdDev  Measure
+	P50     Measure
+	P90     Measure
+	P95     Measure
+	P99     Measure
+	P999    Measure
 }
 
 // PingStatsFromLatencies computes PingStats from a serie of ping measurements.
@@ -34,11 +39,31 @@ func PingStatsFromLatencies(measures []Measure) *PingStats {
 
 	stats := ComputeStats(measuresIterable(measures))
 
+	h := NewHistogram()
+	for _, m := range measures {
+		h.Record(m)
+	}
+
+	ps := PingStatsFromHistogram(h)
+	ps.Min = Measure(stats.Min)
+	ps.Max = Measure(stats.Max)
+	ps.Average = Measure(stats.Average)
+	ps.StdDev = Measure(stats.StdDev)
+	return ps
+}
+
+// PingStatsFromHistogram computes PingStats from the ping measurements recorded in a Histogram.
+func PingStatsFromHistogram(h *Histogram) *PingStats {
 	return &PingStats{
-		Min:     Measure(stats.Min),
-		Max:     Measure(stats.Max),
-		Average: Measure(stats.Average),
-		StdDev:  Measure(stats.StdDev),
+		Min:     h.Min(),
+		Max:     h.Max(),
+		Average: h.Average(),
+		StdDev:  h.StdDev(),
+		P50:     h.Quantile(0.5),
+		P90:     h.Quantile(0.9),
+		P95:     h.Quantile(0.95),
+		P99:     h.Quantile(0.99),
+		P999:    h.Quantile(0.999),
 	}
 }
 
@@ -50,6 +75,15 @@ func (ps *PingStats) String() string {
 	return fmt.Sprintf("round-trip min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms", ms(ps.Min), ms(ps.Average), ms(ps.Max), ms(ps.StdDev))
 }
 
+// PercentilesString returns the percentiles of the latencies in the same format as String
+func (ps *PingStats) PercentilesString() string {
+	ms := func(d Measure) float64 {
+		return d.ToFloat(time.Millisecond)
+	}
+
+	return fmt.Sprintf("round-trip p50/p90/p95/p99/p99.9 = %.3f/%.3f/%.3f/%.3f/%.3f ms", ms(ps.P50), ms(ps.P90), ms(ps.P95), ms(ps.P99), ms(ps.P999))
+}
+
 type measuresIterable []Measure
 
 func (m measuresIterable) Iterator() Iterator {
@@ -87,9 +87,37 @@ const (
 	Req
 	Wait
 	Resp
-	ReqAndWait // temporary for http3, since Req and Wait cannot be distinguished yet with quic-go
+	ReqAndWait   // temporary for http3, since Req and Wait cannot be distinguished yet with quic-go
+	ProxyConnect // connection to the proxy, it replaces the TCP handshake with the target
+	Tunnel       // establishment of the tunnel to the target through the proxy (CONNECT or SOCKS5)
 )
 
+// TimerTypes lists every phase of a request, sub-phases first and total last
+var TimerTypes = []TimerType{DNS, ProxyConnect, Tunnel, TCP, TLS, QUIC, Conn, Req, Wait, ReqAndWait, Resp, Total}
+
+var timerTypeNames = map[TimerType]string{
+	Total:        "total",
+	Conn:         "conn",
+	DNS:          "dns",
+	TLS:          "tls",
+	QUIC:         "quic",
+	TCP:          "tcp",
+	Req:          "req",
+	Wait:         "wait",
+	Resp:         "resp",
+	ReqAndWait:   "req_and_wait",
+	ProxyConnect: "proxy_connect",
+	Tunnel:       "tunnel",
+}
+
+// String returns a short lower-case identifier of the phase, suitable for machine-readable outputs
+func (tt TimerType) String() string {
+	if name, ok := timerTypeNames[tt]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 type TimerRegistry struct {
 	timers map[TimerType]*Timer
 }